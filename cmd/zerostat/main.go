@@ -1,24 +1,52 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/erysngl/zerostat/internal/alerting"
+	"github.com/erysngl/zerostat/internal/audit"
 	"github.com/erysngl/zerostat/internal/auth"
+	"github.com/erysngl/zerostat/internal/certs"
 	"github.com/erysngl/zerostat/internal/config"
 	"github.com/erysngl/zerostat/internal/handlers"
 	"github.com/erysngl/zerostat/internal/i18n"
+	"github.com/erysngl/zerostat/internal/incidents"
+	"github.com/erysngl/zerostat/internal/metrics"
+	"github.com/erysngl/zerostat/internal/tsdb"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "reset-password" {
+		if err := resetPassword(os.Args[2:]); err != nil {
+			log.Fatalf("reset-password: %v", err)
+		}
+		return
+	}
+
 	log.Println("Initializing Config...")
 	config.Init()
 	
+	log.Println("Opening Audit Log...")
+	if err := audit.Init(filepath.Join("data", "audit.log"), config.Get().GetRetention().Audit); err != nil {
+		log.Printf("Warning: audit log unavailable, entries go to stdout: %v", err)
+	}
+
+	log.Println("Opening Incident Store...")
+	if err := incidents.Init(filepath.Join("data", "incidents.jsonl"), config.Get().GetRetention().Incidents); err != nil {
+		log.Printf("Warning: incident store unavailable, events go to stdout: %v", err)
+	}
+	closeStaleIncidents(config.Get())
+
 	log.Println("Initializing Auth...")
 	auth.Init()
+	migratePassword(config.Get())
 
 	log.Println("Initializing Templates and i18n...")
 	i18n.Init()
@@ -26,10 +54,32 @@ func main() {
 	// In production (Docker), we'll ensure templates are in the working directory
 	handlers.InitTemplates()
 
+	cfg := config.Get()
+
+	loginLimit := cfg.GetLoginLimit()
+	auth.ConfigureLoginLimit(loginLimit.MaxFailures, loginLimit.Window, loginLimit.Lockout)
+	auth.SetTrustedProxyHeader(cfg.GetTrustedProxy())
+	applyCollectorFilters(cfg)
+
+	log.Println("Opening Metrics History Store...")
+	retention := cfg.GetRetention()
+	store, err := tsdb.Open(filepath.Join("data", "tsdb"), tsdb.Retention{
+		Raw:    retention.Raw,
+		Minute: retention.Minute,
+		Hour:   retention.Hour,
+	})
+	if err != nil {
+		log.Printf("Warning: metrics history disabled: %v", err)
+	} else {
+		metrics.SetStore(store)
+	}
+
+	log.Println("Starting Metrics Collector...")
+	metrics.StartCollector(cfg.GetSampleInterval())
+
 	log.Println("Starting Alerting Engine...")
 	alerting.StartEngine()
 
-	cfg := config.Get()
 	port := cfg.GetPort()
 
 	mux := http.NewServeMux()
@@ -40,34 +90,162 @@ func main() {
 
 	// Routes
 	mux.HandleFunc("/login", handlers.ServeLogin)
+	mux.HandleFunc("/login/2fa", handlers.ServeLoginSecondFactor)
 	mux.HandleFunc("/logout", handlers.ServeLogout)
 
-	// Protected Routes wrapped in Middleware
-	mux.HandleFunc("/", auth.Middleware(handlers.ServeDashboard))
-	mux.HandleFunc("/api/stats", auth.Middleware(handlers.ServeStats))
-	mux.HandleFunc("/settings", auth.Middleware(handlers.ServeSettings))
-	mux.HandleFunc("/settings/test", auth.Middleware(handlers.TestNotification))
-	mux.HandleFunc("/automation", auth.Middleware(handlers.ServeAutomation))
-	mux.HandleFunc("/automation/add", auth.Middleware(handlers.AddAutomationRule))
-	mux.HandleFunc("/automation/toggle", auth.Middleware(handlers.ToggleAutomationRule))
-	mux.HandleFunc("/automation/delete", auth.Middleware(handlers.DeleteAutomationRule))
+	// Prometheus scraping uses its own optional bearer token instead of the session cookie
+	mux.HandleFunc("/metrics", auth.TokenMiddleware(cfg.GetMetricsToken, handlers.ServeMetrics))
+
+	// Versioned JSON API, authenticated by scoped API tokens rather than the browser session
+	mux.HandleFunc("/api/v1/stats", auth.APIMiddleware(auth.ScopeStatsRead, auth.ScopeStatsRead, handlers.APIStats))
+	mux.HandleFunc("/api/v1/history", auth.APIMiddleware(auth.ScopeStatsRead, auth.ScopeStatsRead, handlers.APIHistory))
+	mux.HandleFunc("/api/v1/processes", auth.APIMiddleware(auth.ScopeStatsRead, auth.ScopeStatsRead, handlers.APIProcesses))
+	mux.HandleFunc("/api/v1/processes/", auth.APIMiddleware(auth.ScopeProcessControl, auth.ScopeProcessControl, handlers.APIKillProcess))
+	mux.HandleFunc("/api/v1/containers/", auth.APIMiddleware(auth.ScopeProcessControl, auth.ScopeProcessControl, handlers.APIStopContainer))
+	mux.HandleFunc("/api/v1/rules", auth.APIMiddleware(auth.ScopeStatsRead, auth.ScopeRulesManage, handlers.APIRules))
+	mux.HandleFunc("/api/v1/rules/", auth.APIMiddleware(auth.ScopeStatsRead, auth.ScopeRulesManage, handlers.APIRule))
+
+	// Protected Routes wrapped in Middleware. Viewers only get the dashboard, operators
+	// may act on processes and containers, admins manage settings and automation.
+	mux.HandleFunc("/", auth.RequireRole(auth.RoleViewer, handlers.ServeDashboard))
+	mux.HandleFunc("/api/stats", auth.RequireRole(auth.RoleViewer, handlers.ServeStats))
+	mux.HandleFunc("/api/history", auth.RequireRole(auth.RoleViewer, handlers.ServeHistory))
+	// Every user may open settings to manage their own second factor; ServeSettings
+	// itself only lets admins change configuration
+	mux.HandleFunc("/settings", auth.RequireRole(auth.RoleViewer, handlers.ServeSettings))
+	mux.HandleFunc("/settings/2fa/begin", auth.RequireRole(auth.RoleViewer, handlers.BeginTwoFactor))
+	mux.HandleFunc("/settings/2fa/confirm", auth.RequireRole(auth.RoleViewer, handlers.ConfirmTwoFactor))
+	mux.HandleFunc("/settings/2fa/disable", auth.RequireRole(auth.RoleViewer, handlers.DisableTwoFactor))
+	mux.HandleFunc("/settings/test", auth.RequireRole(auth.RoleAdmin, handlers.TestNotification))
+	mux.HandleFunc("/settings/tokens/create", auth.RequireRole(auth.RoleAdmin, handlers.CreateAPIToken))
+	mux.HandleFunc("/settings/tokens/revoke", auth.RequireRole(auth.RoleAdmin, handlers.RevokeAPIToken))
+	mux.HandleFunc("/settings/users/create", auth.RequireRole(auth.RoleAdmin, handlers.CreateUser))
+	mux.HandleFunc("/settings/users/role", auth.RequireRole(auth.RoleAdmin, handlers.SetUserRole))
+	mux.HandleFunc("/settings/users/delete", auth.RequireRole(auth.RoleAdmin, handlers.DeleteUser))
+	mux.HandleFunc("/settings/users/reset2fa", auth.RequireRole(auth.RoleAdmin, handlers.ResetUserTwoFactor))
+	mux.HandleFunc("/settings/lockouts/clear", auth.RequireRole(auth.RoleAdmin, handlers.ClearLockout))
+	mux.HandleFunc("/settings/sessions/rotate", auth.RequireRole(auth.RoleAdmin, handlers.RotateSessionKeys))
+	mux.HandleFunc("/settings/sessions/revoke", auth.RequireRole(auth.RoleAdmin, handlers.RevokeAllSessions))
+	mux.HandleFunc("/audit", auth.RequireRole(auth.RoleAdmin, handlers.ServeAudit))
+	mux.HandleFunc("/audit/export", auth.RequireRole(auth.RoleAdmin, handlers.ExportAudit))
+	mux.HandleFunc("/automation", auth.RequireRole(auth.RoleAdmin, handlers.ServeAutomation))
+	mux.HandleFunc("/automation/add", auth.RequireRole(auth.RoleAdmin, handlers.AddAutomationRule))
+	mux.HandleFunc("/automation/toggle", auth.RequireRole(auth.RoleAdmin, handlers.ToggleAutomationRule))
+	mux.HandleFunc("/automation/delete", auth.RequireRole(auth.RoleAdmin, handlers.DeleteAutomationRule))
+	mux.HandleFunc("/incidents", auth.RequireRole(auth.RoleAdmin, handlers.ServeIncidents))
 	
-	mux.HandleFunc("/tasks", auth.Middleware(handlers.ServeTasks))
-	mux.HandleFunc("/tasks/list", auth.Middleware(handlers.ServeTasksList))
-	mux.HandleFunc("/tasks/kill", auth.Middleware(handlers.HandleKillProcess))
-	mux.HandleFunc("/tasks/stop_container", auth.Middleware(handlers.HandleStopContainer))
-
-	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	mux.HandleFunc("/tasks", auth.RequireRole(auth.RoleOperator, handlers.ServeTasks))
+	mux.HandleFunc("/tasks/list", auth.RequireRole(auth.RoleOperator, handlers.ServeTasksList))
+	mux.HandleFunc("/tasks/kill", auth.RequireRole(auth.RoleOperator, handlers.HandleKillProcess))
+	mux.HandleFunc("/tasks/stop_container", auth.RequireRole(auth.RoleOperator, handlers.HandleStopContainer))
+
+	srv := &appServer{handler: mux}
+	var redirectServer *http.Server
+
+	tlsCfg := cfg.GetTLS()
+	if tlsCfg.Enabled {
+		certManager, err := certs.New(tlsCfg.CertFile, tlsCfg.KeyFile, filepath.Join("data", "tls"))
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		certManager.Watch(30 * time.Second)
+		srv.tlsConfig = certManager.TLSConfig()
+		auth.SetSecureCookies(true)
+		if certManager.SelfSigned() {
+			log.Printf("Using self-signed certificate %s, browsers will show a warning until it is trusted", certManager.CertFile())
+		}
 	}
 
 	log.Printf("Starting ZeroStat on :%s ...", port)
-	err := server.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
+	if err := srv.listen(port); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
+	if tlsCfg.Enabled && tlsCfg.RedirectPort != "" {
+		redirectServer = serveHTTPSRedirect(tlsCfg.RedirectPort, srv.currentPort)
+	}
+	handlers.SetRebinder(srv.rebind)
+
+	// SIGHUP reloads .env and the rules, SIGINT/SIGTERM shut down gracefully
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	sig := <-signals
+	for sig == syscall.SIGHUP {
+		reloadConfig(srv, store)
+		sig = <-signals
+	}
+
+	log.Printf("Received %s, shutting down...", sig)
+	alerting.StopEngine()
+	metrics.StopCollector()
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.Printf("Error flushing metrics history: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if redirectServer != nil {
+		redirectServer.Shutdown(ctx)
+	}
+	if err := srv.shutdown(ctx); err != nil {
+		log.Printf("Error during shutdown: %v", err)
+	}
+	log.Println("ZeroStat stopped")
+}
+
+// closeStaleIncidents resolves the incidents a previous run left open for rules
+// that start untriggered. Rule state is only saved along with edits, so those
+// rules would never send the recovery that closes them.
+func closeStaleIncidents(cfg *config.Config) {
+	for _, id := range incidents.OpenRules() {
+		if rule, ok := cfg.GetRule(id); !ok || !rule.HasTriggered {
+			incidents.Close(id, "restarted")
+		}
+	}
+}
+
+// applyCollectorFilters hands the filesystem and interface selection to the metrics collector.
+func applyCollectorFilters(cfg *config.Config) {
+	disks := cfg.GetDisks()
+	metrics.SetDiskFilter(disks.HostRoot, metrics.SplitPatterns(disks.Include), metrics.SplitPatterns(disks.Exclude))
+	metrics.SetInterfaceFilter(metrics.SplitPatterns(cfg.GetNetInterfaces()))
+}
+
+// reloadConfig applies a changed .env and rules file without a restart. The sample
+// interval and TLS settings still require one.
+func reloadConfig(srv *appServer, store *tsdb.Store) {
+	log.Println("Received SIGHUP, reloading configuration...")
+	if err := config.Reload(); err != nil {
+		log.Printf("Error reloading configuration: %v", err)
+		return
+	}
+
+	cfg := config.Get()
+	migratePassword(cfg)
+
+	loginLimit := cfg.GetLoginLimit()
+	auth.ConfigureLoginLimit(loginLimit.MaxFailures, loginLimit.Window, loginLimit.Lockout)
+	auth.SetTrustedProxyHeader(cfg.GetTrustedProxy())
+	applyCollectorFilters(cfg)
+
+	if store != nil {
+		retention := cfg.GetRetention()
+		store.SetRetention(tsdb.Retention{
+			Raw:    retention.Raw,
+			Minute: retention.Minute,
+			Hour:   retention.Hour,
+		})
+	}
+	incidents.SetRetention(cfg.GetRetention().Incidents)
+	audit.SetRetention(cfg.GetRetention().Audit)
+
+	entry := audit.Entry{User: audit.SystemUser, Action: audit.ActionSettingsReload, Outcome: audit.Success, Detail: "SIGHUP"}
+	if err := srv.rebind(cfg.GetPort()); err != nil {
+		log.Printf("Error moving to port %s, still on the previous port: %v", cfg.GetPort(), err)
+		entry.Outcome = audit.Failure
+		entry.Detail = "SIGHUP: " + err.Error()
+	}
+	audit.Record(entry)
+	log.Println("Configuration reloaded")
 }
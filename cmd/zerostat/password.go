@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/erysngl/zerostat/internal/auth"
+	"github.com/erysngl/zerostat/internal/config"
+)
+
+// envOverride explains why a password written to .env would not take effect.
+const envOverride = "ZEROSTAT_PASSWORD is set in the process environment (e.g. docker-compose or a systemd unit), which takes precedence over .env"
+
+// migratePassword accepts a plaintext ZEROSTAT_PASSWORD one last time and rewrites
+// .env with its hash, so the secret never stays on disk in the clear.
+func migratePassword(cfg *config.Config) {
+	current := cfg.GetPasswordHash()
+	if auth.IsPasswordHash(current) {
+		return
+	}
+
+	hash, err := auth.HashPassword(current)
+	if err != nil {
+		log.Fatalf("Failed to hash admin password: %v", err)
+	}
+	cfg.SetPasswordHash(hash)
+	if config.FromEnvironment("ZEROSTAT_PASSWORD") {
+		// Saving would leave the plaintext in place and make .env look migrated
+		log.Printf("WARNING: %s, so the plaintext password cannot be replaced by a hash. Remove it from the environment and run \"zerostat reset-password\" to store it hashed in .env.", envOverride)
+		return
+	}
+	cfg.SaveEnv()
+	log.Println("Migrated plaintext ZEROSTAT_PASSWORD to a password hash in .env")
+}
+
+// resetPassword implements the offline "zerostat reset-password" subcommand. The new
+// password is taken from -password or, when omitted, read as one line from stdin.
+// -disable-2fa also removes the built-in admin's second factor.
+func resetPassword(args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	password := fs.String("password", "", "new admin password (read from stdin when empty)")
+	disable2FA := fs.Bool("disable-2fa", false, "also remove the admin's two-factor authentication")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zerostat reset-password [-password <new password>] [-disable-2fa]")
+		fmt.Fprintln(fs.Output(), "Rewrites ZEROSTAT_PASSWORD in .env. Restart a running server afterwards.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config.Init()
+	if config.FromEnvironment("ZEROSTAT_PASSWORD") {
+		return fmt.Errorf("%s, so a new password in .env would be ignored. Remove it from the environment first", envOverride)
+	}
+
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "New admin password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("reading password: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+	if *password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	hash, err := auth.HashPassword(*password)
+	if err != nil {
+		return err
+	}
+	cfg := config.Get()
+	cfg.SetPasswordHash(hash)
+	cfg.SaveEnv()
+
+	fmt.Fprintln(os.Stderr, "Admin password updated in .env")
+
+	if *disable2FA {
+		auth.LoadTOTP()
+		if auth.DisableTOTP(auth.BootstrapAdmin) {
+			fmt.Fprintln(os.Stderr, "Two-factor authentication removed for admin")
+		}
+	}
+	return nil
+}
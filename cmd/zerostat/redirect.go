@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveHTTPSRedirect listens for plain HTTP on port and sends every request to the
+// same path on the HTTPS port, looked up per request because the dashboard can
+// move to another port while running. The redirect is temporary for the same
+// reason, a cached permanent one would outlive the port. The returned server is
+// closed on shutdown.
+func serveHTTPSRedirect(port string, tlsPort func() string) *http.Server {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tlsPort := tlsPort()
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]") // bare IPv6 literal
+		}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      redirect,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	log.Printf("Redirecting HTTP on :%s to HTTPS on :%s", port, tlsPort())
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP redirect listener failed: %v", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish when a
+// listener is closed, on shutdown or after moving to another port.
+const shutdownTimeout = 15 * time.Second
+
+// appServer owns the dashboard listener and can move it to another port while
+// running. Requests still in flight on the old port are allowed to finish.
+type appServer struct {
+	handler   http.Handler
+	tlsConfig *tls.Config // nil serves plain HTTP
+
+	mu      sync.Mutex
+	current *http.Server
+	port    string
+}
+
+// listen binds port and starts serving on it, then drains the previous listener
+// in the background. Binding happens first so a bad port leaves the old one running.
+func (s *appServer) listen(port string) error {
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:         ":" + port,
+		Handler:      s.handler,
+		TLSConfig:    s.tlsConfig,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	s.mu.Lock()
+	previous := s.current
+	s.current = server
+	s.port = port
+	s.mu.Unlock()
+
+	go func() {
+		var err error
+		if s.tlsConfig != nil {
+			err = server.ServeTLS(ln, "", "")
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	if previous != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := previous.Shutdown(ctx); err != nil {
+				log.Printf("Error closing listener on %s: %v", previous.Addr, err)
+			}
+		}()
+	}
+
+	scheme := "HTTP"
+	if s.tlsConfig != nil {
+		scheme = "HTTPS"
+	}
+	log.Printf("Listening for %s on :%s", scheme, port)
+	return nil
+}
+
+// rebind moves the dashboard to port, doing nothing if it already listens there.
+func (s *appServer) rebind(port string) error {
+	s.mu.Lock()
+	same := s.port == port
+	s.mu.Unlock()
+	if same {
+		return nil
+	}
+	return s.listen(port)
+}
+
+// currentPort is the port the dashboard listens on right now.
+func (s *appServer) currentPort() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.port
+}
+
+// shutdown stops accepting connections and waits for in-flight requests.
+func (s *appServer) shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.current
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -14,54 +15,74 @@ import (
 	"strings"
 	"time"
 
+	"github.com/erysngl/zerostat/internal/audit"
 	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/incidents"
 	"github.com/erysngl/zerostat/internal/metrics"
 )
 
+var (
+	engineStop chan struct{}
+	engineDone chan struct{}
+)
+
 // Start Engine kicks off the stateful background evaluator
 func StartEngine() {
+	engineStop = make(chan struct{})
+	engineDone = make(chan struct{})
 	go func() {
+		defer close(engineDone)
 		ticker := time.NewTicker(3 * time.Second)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			evaluateRules()
+		for {
+			select {
+			case <-ticker.C:
+				evaluateRules()
+			case <-engineStop:
+				return
+			}
 		}
 	}()
 }
 
+// StopEngine stops the evaluator, waiting for a running evaluation to finish.
+// Notifications and shell commands already dispatched keep running.
+func StopEngine() {
+	if engineStop == nil {
+		return
+	}
+	close(engineStop)
+	<-engineDone
+	engineStop = nil
+}
+
 func evaluateRules() {
 	cfg := config.Get()
 	rules := cfg.GetRules()
+	metrics.KeepHistory(trendInstances(rules))
 	
 	if len(rules) == 0 {
 		return
 	}
 
-	stats := metrics.GetStats()
+	stats := metrics.Latest()
 
 	for _, rule := range rules {
 		if !rule.IsActive {
 			continue
 		}
 
-		currentValue := getMetricValue(rule.MetricType, stats)
-		
-		isViolating := false
-		switch rule.Operator {
-		case ">":
-			isViolating = currentValue > rule.ThresholdPercent
-		case "<":
-			isViolating = currentValue < rule.ThresholdPercent
-		case "==":
-			// Floating point exact match is tricky, let's use a very small epsilon
-			isViolating = math.Abs(currentValue-rule.ThresholdPercent) < 0.01
-		default:
-			// Fallback to strict greater equals if undefined
-			isViolating = currentValue >= rule.ThresholdPercent
+		obs, ok := observe(rule, stats)
+		if !ok {
+			continue
 		}
-
-		if isViolating {
+		
+		if obs.Violating {
+			if rule.RecoveringSince != nil {
+				// Recovery has to be sustained, start over next time
+				cfg.SetRuleRecovering(rule.ID, nil)
+			}
 			if rule.ViolatingSince == nil {
 				// Record First Violation Time
 				now := time.Now()
@@ -76,23 +97,45 @@ func evaluateRules() {
 			if violationDuration >= requiredDuration {
 				if !rule.HasTriggered {
 					// Trigger the first action
-					executeAction(rule, currentValue)
+					incident := openIncident(rule, obs)
+					executeAction(rule, obs, incident)
 					cfg.UpdateRuleState(rule.ID, rule.ViolatingSince, true)
 					cfg.MarkRuleSent(rule.ID)
 				} else if rule.CooldownSeconds > 0 && rule.LastSentAt != nil {
 					// Check if Cooldown elapsed for subsequent alerts
 					if time.Since(*rule.LastSentAt) >= time.Duration(rule.CooldownSeconds)*time.Second {
-						executeAction(rule, currentValue)
+						incidents.Record(incidents.Event{RuleID: rule.ID, Kind: incidents.KindRepeat, Value: obs.current(rule)})
+						executeAction(rule, obs, incidents.Current(rule.ID))
 						cfg.MarkRuleSent(rule.ID)
 					}
 				}
 			}
+			if rule.Expression == "" {
+				incidents.Observe(rule.ID, obs.Value, FiresBelow(rule.Operator))
+			}
 		} else {
-			// Did it recover?
+			// Did it recover? With hysteresis the value has to get back past the
+			// recovery threshold and stay there for RecoverySeconds
 			if rule.HasTriggered {
-				sendRecoveryNotification(rule, currentValue)
+				if !recovered(rule, obs) {
+					if rule.RecoveringSince != nil {
+						cfg.SetRuleRecovering(rule.ID, nil)
+					}
+					continue
+				}
+				if rule.RecoverySeconds > 0 {
+					if rule.RecoveringSince == nil {
+						now := time.Now()
+						cfg.SetRuleRecovering(rule.ID, &now)
+						continue
+					}
+					if time.Since(*rule.RecoveringSince) < time.Duration(rule.RecoverySeconds)*time.Second {
+						continue
+					}
+				}
+				sendRecoveryNotification(rule, obs)
 			}
-			// Reset state immediately since it dropped
+			// Reset state since it dropped
 			if rule.ViolatingSince != nil || rule.HasTriggered {
 				cfg.UpdateRuleState(rule.ID, nil, false)
 			}
@@ -100,30 +143,136 @@ func evaluateRules() {
 	}
 }
 
-func getMetricValue(metricType string, stats *metrics.SystemStats) float64 {
-	switch metricType {
-	case "CPU":
-		return stats.CPUUsage
-	case "RAM":
-		return stats.MemUsage
-	case "Disk":
-		return stats.DiskUsage
+// compare applies a rule operator to a value.
+func compare(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case ">":
+		return value > threshold
+	case "<":
+		return value < threshold
+	case "==":
+		// Floating point exact match is tricky, let's use a very small epsilon
+		return math.Abs(value-threshold) < 0.01
+	default:
+		// Fallback to strict greater equals if undefined
+		return value >= threshold
+	}
+}
+
+// FiresBelow reports whether rules with operator fire when the value falls
+// under the threshold rather than when it rises above it.
+func FiresBelow(operator string) bool {
+	return operator == "<" || operator == OpAvgBelow || operator == OpFullIn
+}
+
+// openIncident starts the incident of a rule that just triggered.
+func openIncident(rule config.AlertRule, obs observation) string {
+	e := incidents.Event{RuleID: rule.ID, Condition: RuleCondition(rule), Value: obs.current(rule)}
+	if rule.Expression == "" {
+		// Expression rules compare several values, none of which is the peak
+		peak := obs.Value
+		e.Peak, e.Unit = &peak, unitSuffix(rule)
+	}
+	return incidents.Start(e)
+}
+
+// recovered reports whether a triggered rule whose condition no longer holds is
+// also past its recovery threshold, if it has one.
+func recovered(rule config.AlertRule, obs observation) bool {
+	if rule.RecoveryThreshold == nil {
+		return true
+	}
+	if IsTrend(rule.Operator) {
+		return !trendViolates(rule.Operator, obs.Value, *rule.RecoveryThreshold)
+	}
+	return !compare(obs.Value, rule.Operator, *rule.RecoveryThreshold)
+}
+
+// observation is the state of a rule's condition in one sample.
+type observation struct {
+	Violating bool
+	Value     float64 // current value of a single metric rule
+	Values    string  // every compared value of an expression rule, e.g. "CPU 95.00%, RAM 85.00%"
+}
+
+// observe evaluates rule against a sample. ok is false when a metric the rule
+// needs is missing, in which case its state is left alone.
+func observe(rule config.AlertRule, stats *metrics.SystemStats) (observation, bool) {
+	if rule.Expression != "" {
+		expr, err := ParseExpression(rule.Expression)
+		if err != nil {
+			return observation{}, false
+		}
+		violating, ok := expr.Eval(stats)
+		return observation{Violating: violating, Values: expr.Values(stats)}, ok
+	}
+
+	if IsTrend(rule.Operator) {
+		value, ok := trendValue(rule)
+		if !ok {
+			return observation{}, false
+		}
+		return observation{Violating: trendViolates(rule.Operator, value, rule.ThresholdPercent), Value: value}, true
+	}
+
+	value, ok := stats.Value(rule.MetricType, rule.ThresholdUnit())
+	if !ok {
+		return observation{}, false
+	}
+	return observation{Violating: compare(value, rule.Operator, rule.ThresholdPercent), Value: value}, true
+}
+
+// unitSuffix is the display suffix of the rule's threshold and observed value.
+func unitSuffix(rule config.AlertRule) string {
+	suffix := metrics.UnitSuffix(rule.ThresholdUnit())
+	if rule.Operator == OpRate {
+		suffix += "/min"
+	}
+	return suffix
+}
+
+// current formats the observed value(s) with their units.
+func (o observation) current(rule config.AlertRule) string {
+	if rule.Expression != "" {
+		return o.Values
 	}
-	return 0.0
+	return fmt.Sprintf("%.2f%s", o.Value, unitSuffix(rule))
 }
 
-func executeAction(rule config.AlertRule, currentVal float64) {
-	log.Printf("[ALERT] Rule triggered! %s %s %.2f%% (Current: %.2f%%). Action: %s", 
-		rule.MetricType, rule.Operator, rule.ThresholdPercent, currentVal, rule.ShellCommand)
+// RuleCondition is the rule's condition as written on the automation page.
+func RuleCondition(rule config.AlertRule) string {
+	if rule.Expression != "" {
+		return rule.Expression
+	}
+	cond := fmt.Sprintf("%s %s %.2f%s", rule.MetricType, rule.Operator, rule.ThresholdPercent, unitSuffix(rule))
+	if IsTrend(rule.Operator) {
+		cond += fmt.Sprintf(" over %dm", rule.WindowMinutes)
+	}
+	return cond
+}
+
+func executeAction(rule config.AlertRule, obs observation, incident string) {
+	log.Printf("[ALERT] Rule triggered! %s (Current: %s). Action: %s", RuleCondition(rule), obs.current(rule), rule.ShellCommand)
 	
 	// Send notification if requested
 	if rule.NotificationChannel != "" && rule.NotificationChannel != "none" {
-		msg := buildMessage(rule, currentVal, false)
-		go sendNotification(rule.NotificationChannel, msg)
+		msg := buildMessage(rule, obs, false)
+		go notify(rule, incident, msg)
 	}
 
 	if rule.ShellCommand != "" {
-		go executeSafeShell(rule.ShellCommand)
+		go executeSafeShell(rule.ID, incident, rule.ShellCommand)
+	}
+}
+
+// notify sends a rule's notification and records the result in its incident.
+func notify(rule config.AlertRule, incident, message string) {
+	e := incidents.Event{Incident: incident, RuleID: rule.ID, Kind: incidents.KindNotification, Channel: rule.NotificationChannel, Outcome: incidents.Success}
+	if err := sendNotification(rule.NotificationChannel, message); err != nil {
+		e.Outcome, e.Detail = incidents.Failure, err.Error()
+	}
+	if incident != "" {
+		incidents.Record(e)
 	}
 }
 
@@ -151,9 +300,27 @@ func containsShellInjection(cmd string) bool {
 	return false
 }
 
-func executeSafeShell(command string) {
+func executeSafeShell(ruleID, incident, command string) {
+	entry := audit.Entry{
+		User:    audit.SystemUser,
+		Action:  audit.ActionShellExec,
+		Target:  command,
+		Outcome: audit.Success,
+		Detail:  "rule " + ruleID,
+	}
+	event := incidents.Event{Incident: incident, RuleID: ruleID, Kind: incidents.KindAction, Command: command, Outcome: incidents.Success}
+	defer func() {
+		if incident != "" {
+			incidents.Record(event)
+		}
+	}()
+
 	if containsShellInjection(command) {
 		log.Printf("[ALERT-SECURITY] Blocked potentially unsafe shell command: %s", command)
+		entry.Outcome = audit.Denied
+		entry.Detail += ": blocked as potentially unsafe"
+		audit.Record(entry)
+		event.Outcome, event.Detail = incidents.Denied, "blocked as potentially unsafe"
 		return
 	}
 
@@ -165,35 +332,78 @@ func executeSafeShell(command string) {
 	// Sh -c format supports standard bash pipes and operators like $(docker ps -q)
 	cmd := exec.CommandContext(ctx, "sh", "-c", command)
 	output, err := cmd.CombinedOutput()
+	event.Output = truncateOutput(output)
+	if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() >= 0 {
+		code := cmd.ProcessState.ExitCode()
+		event.ExitCode = &code
+	}
 	
 	if ctx.Err() == context.DeadlineExceeded {
 		log.Printf("[ALERT-ACTION] Command timed out after 30s: %s", command)
+		entry.Outcome = audit.Failure
+		entry.Detail += ": timed out after 30s"
+		audit.Record(entry)
+		event.Outcome, event.Detail = incidents.Failure, "timed out after 30s"
 		return
 	}
 	
 	if err != nil {
 		log.Printf("[ALERT-ACTION] Execution failed: %v | Output: %s", err, string(output))
+		entry.Outcome = audit.Failure
+		entry.Detail += ": " + err.Error()
+		audit.Record(entry)
+		event.Outcome, event.Detail = incidents.Failure, err.Error()
 		return
 	}
 	
 	log.Printf("[ALERT-ACTION] Execution succeeded. Output: %s", string(output))
+	audit.Record(entry)
 }
 
-func sendRecoveryNotification(rule config.AlertRule, currentVal float64) {
-	log.Printf("[RECOVERY] System recovered for %s rule. Current Value: %.2f%%.", rule.MetricType, currentVal)
+// truncateOutput keeps the start of a command's output for its incident.
+func truncateOutput(output []byte) string {
+	if len(output) > incidents.MaxOutput {
+		return string(output[:incidents.MaxOutput]) + "\n[truncated]"
+	}
+	return string(output)
+}
+
+func sendRecoveryNotification(rule config.AlertRule, obs observation) {
+	name := rule.MetricType
+	if rule.Expression != "" {
+		name = rule.Expression
+	}
+	log.Printf("[RECOVERY] System recovered for %s rule. Current Value: %s.", name, obs.current(rule))
+
+	incident := incidents.Current(rule.ID)
+	incidents.Record(incidents.Event{RuleID: rule.ID, Kind: incidents.KindRecovered, Value: obs.current(rule)})
 	
 	if rule.NotificationChannel != "" && rule.NotificationChannel != "none" {
-		msg := buildMessage(rule, currentVal, true)
-		go sendNotification(rule.NotificationChannel, msg)
+		msg := buildMessage(rule, obs, true)
+		go notify(rule, incident, msg)
 	}
 }
 
-func buildMessage(rule config.AlertRule, currentVal float64, isRecovery bool) string {
+func buildMessage(rule config.AlertRule, obs observation, isRecovery bool) string {
 	template := rule.MessageTemplate
-	if isRecovery && template == "" {
-		template = "[ZeroStat-Go] {hostname} Recovery: {metric} is now at {value}%. System is safe."
+	if rule.Expression != "" && template == "" {
+		// Expression rules have no single value, threshold or unit to fill in
+		if isRecovery {
+			template = "[ZeroStat-Go] {hostname} Recovery: {metric} no longer holds ({value}). System is safe."
+		} else {
+			template = "[ZeroStat-Go] {hostname} Warning: {metric} holds ({value})! (Duration: {duration}s)"
+		}
+	} else if IsTrend(rule.Operator) && template == "" {
+		// The value of a trend rule is the derived rate, change, average or hours left
+		if isRecovery {
+			template = "[ZeroStat-Go] {hostname} Recovery: {metric} {operator} {threshold}{unit} over the last {window} minutes no longer holds (now {value}{unit}). System is safe."
+		} else {
+			template = "[ZeroStat-Go] {hostname} Warning: {metric} {operator} {threshold}{unit} over the last {window} minutes (now {value}{unit}, Duration: {duration}s)"
+		}
+	} else if isRecovery && template == "" {
+		template = "[ZeroStat-Go] {hostname} Recovery: {metric} is now at {value}{unit}. System is safe."
 	} else if template == "" {
-		template = "[ZeroStat-Go] {hostname} Warning: {metric} value is {value}%! (Threshold: {operator}{threshold}, Duration: {duration}s)"
+		template = "[ZeroStat-Go] {hostname} Warning: {metric} value is {value}{unit}! (Threshold: {operator}{threshold}{unit}, Duration: {duration}s)"
 	}
 
 	hostname, _ := os.Hostname()
@@ -201,17 +411,27 @@ func buildMessage(rule config.AlertRule, currentVal float64, isRecovery bool) st
 		hostname = "unknown-host"
 	}
 
+	metric, value := rule.MetricType, fmt.Sprintf("%.2f", obs.Value)
+	threshold, unit := fmt.Sprintf("%.2f", rule.ThresholdPercent), unitSuffix(rule)
+	if rule.Expression != "" {
+		metric, value, threshold, unit = rule.Expression, obs.Values, "", ""
+	}
+
 	msg := strings.ReplaceAll(template, "{hostname}", hostname)
-	msg = strings.ReplaceAll(msg, "{metric}", rule.MetricType)
-	msg = strings.ReplaceAll(msg, "{value}", fmt.Sprintf("%.2f", currentVal))
-	msg = strings.ReplaceAll(msg, "{threshold}", fmt.Sprintf("%.2f", rule.ThresholdPercent))
+	msg = strings.ReplaceAll(msg, "{metric}", metric)
+	msg = strings.ReplaceAll(msg, "{value}", value)
+	msg = strings.ReplaceAll(msg, "{threshold}", threshold)
+	msg = strings.ReplaceAll(msg, "{unit}", unit)
 	msg = strings.ReplaceAll(msg, "{operator}", rule.Operator)
 	msg = strings.ReplaceAll(msg, "{duration}", fmt.Sprintf("%d", rule.DurationSeconds))
+	msg = strings.ReplaceAll(msg, "{window}", fmt.Sprintf("%d", rule.WindowMinutes))
 
 	return msg
 }
 
-func sendNotification(channel, message string) {
+// sendNotification dispatches message to channel, returning why it could not be
+// delivered.
+func sendNotification(channel, message string) error {
 	log.Printf("[NOTIFICATION-DISPATCH] Channel: %s | Payload: %s", channel, message)
 	
 	notif := config.Get().GetNotif()
@@ -224,11 +444,15 @@ func sendNotification(channel, message string) {
 			resp, err := http.Post(notif.WebhookUrl, "application/json", bytes.NewBuffer(jsonPayload))
 			if err != nil {
 				log.Printf("[ERROR] Webhook failed: %v", err)
-			} else {
-				resp.Body.Close()
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("webhook returned %s", resp.Status)
 			}
 		} else {
 			log.Println("[WARNING] Webhook URL not configured.")
+			return errors.New("webhook URL not configured")
 		}
 	case "telegram":
 		if notif.TgBotToken != "" && notif.TgChatId != "" {
@@ -238,11 +462,16 @@ func sendNotification(channel, message string) {
 			resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonPayload))
 			if err != nil {
 				log.Printf("[ERROR] Telegram HTTP failed: %v", err)
-			} else {
-				resp.Body.Close()
+				// The request URL carries the bot token, keep it out of the error
+				return fmt.Errorf("telegram request failed: %v", errors.Unwrap(err))
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("telegram returned %s", resp.Status)
 			}
 		} else {
 			log.Println("[WARNING] Telegram target not configured.")
+			return errors.New("telegram target not configured")
 		}
 	case "email":
 		if notif.SmtpHost != "" && notif.SmtpTo != "" {
@@ -254,11 +483,16 @@ func sendNotification(channel, message string) {
 			err := smtp.SendMail(addr, auth, notif.SmtpUser, []string{notif.SmtpTo}, msg)
 			if err != nil {
 				log.Printf("[ERROR] Email SMTP failed: %v", err)
+				return err
 			}
 		} else {
 			log.Println("[WARNING] SMTP settings missing.")
+			return errors.New("SMTP settings missing")
 		}
+	default:
+		return fmt.Errorf("unknown channel %q", channel)
 	}
+	return nil
 }
 
 // SendTestNotification exports the internal dispatcher for manual testing from UI
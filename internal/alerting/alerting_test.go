@@ -0,0 +1,72 @@
+package alerting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/erysngl/zerostat/internal/config"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		value     float64
+		operator  string
+		threshold float64
+		want      bool
+	}{
+		{95, ">", 90, true},
+		{90, ">", 90, false},
+		{5, "<", 10, true},
+		{10, "<", 10, false},
+		{50.004, "==", 50, true},
+		{50.1, "==", 50, false},
+		{90, "", 90, true},
+	}
+	for _, tt := range tests {
+		if got := compare(tt.value, tt.operator, tt.threshold); got != tt.want {
+			t.Errorf("%v %s %v: got %v, want %v", tt.value, tt.operator, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestRecovered(t *testing.T) {
+	recovery := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		rule  config.AlertRule
+		value float64
+		want  bool
+	}{
+		{"no recovery threshold", config.AlertRule{Operator: ">", ThresholdPercent: 90}, 89, true},
+		{"above between thresholds", config.AlertRule{Operator: ">", ThresholdPercent: 90, RecoveryThreshold: recovery(80)}, 85, false},
+		{"above past recovery", config.AlertRule{Operator: ">", ThresholdPercent: 90, RecoveryThreshold: recovery(80)}, 75, true},
+		{"above at recovery", config.AlertRule{Operator: ">", ThresholdPercent: 90, RecoveryThreshold: recovery(80)}, 80, true},
+		{"below between thresholds", config.AlertRule{Operator: "<", ThresholdPercent: 10, RecoveryThreshold: recovery(20)}, 15, false},
+		{"below past recovery", config.AlertRule{Operator: "<", ThresholdPercent: 10, RecoveryThreshold: recovery(20)}, 25, true},
+		{"avg between thresholds", config.AlertRule{Operator: OpAvgAbove, ThresholdPercent: 90, RecoveryThreshold: recovery(70)}, 80, false},
+		{"avg past recovery", config.AlertRule{Operator: OpAvgAbove, ThresholdPercent: 90, RecoveryThreshold: recovery(70)}, 60, true},
+		{"avg below past recovery", config.AlertRule{Operator: OpAvgBelow, ThresholdPercent: 10, RecoveryThreshold: recovery(20)}, 25, true},
+		{"rate between thresholds", config.AlertRule{Operator: OpRate, ThresholdPercent: 5, RecoveryThreshold: recovery(1)}, 2, false},
+		{"full between thresholds", config.AlertRule{Operator: OpFullIn, ThresholdPercent: 24, RecoveryThreshold: recovery(48)}, 30, false},
+		{"full past recovery", config.AlertRule{Operator: OpFullIn, ThresholdPercent: 24, RecoveryThreshold: recovery(48)}, 72, true},
+		{"full flat series", config.AlertRule{Operator: OpFullIn, ThresholdPercent: 24, RecoveryThreshold: recovery(48)}, math.Inf(1), true},
+	}
+	for _, tt := range tests {
+		if got := recovered(tt.rule, observation{Value: tt.value}); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFiresBelow(t *testing.T) {
+	for _, op := range []string{"<", OpAvgBelow, OpFullIn} {
+		if !FiresBelow(op) {
+			t.Errorf("FiresBelow(%q) = false", op)
+		}
+	}
+	for _, op := range []string{">", "==", OpRate, OpDelta, OpAvgAbove} {
+		if FiresBelow(op) {
+			t.Errorf("FiresBelow(%q) = true", op)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package alerting
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erysngl/zerostat/internal/metrics"
+)
+
+// Condition compares one metric against a threshold, like a single metric rule.
+type Condition struct {
+	Metric    string // a metrics.Catalog name, optionally with an instance as in "Disk:/var"
+	Operator  string // ">", "<" or "=="
+	Threshold float64
+	Unit      string // empty means the metric's default unit
+}
+
+// Expr is a parsed alert expression such as "CPU > 90 && RAM > 80": either a
+// single Condition or an "&&" / "||" combination of sub-expressions.
+type Expr struct {
+	Cond *Condition
+	Op   string
+	Args []*Expr
+}
+
+// Term is one piece of an expression's display form: a comparison, a
+// connective ("&&" or "||") or a parenthesis.
+type Term struct {
+	Text       string
+	Connective bool
+}
+
+// ParseExpression parses conditions of the form "<metric> <operator> <number>
+// [unit]" joined by "&&" and "||", where "&&" binds tighter and parentheses
+// group. A metric whose instance contains spaces or any of ()&|<>=! is written
+// as a Go string literal, e.g. "Disk:/mnt/My Data" > 90. Metric names and
+// units are not checked against the catalog.
+func ParseExpression(src string) (*Expr, error) {
+	p := &parser{src: src}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return e, nil
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *parser) rest() string {
+	rest := p.src[p.pos:]
+	if len(rest) > 20 {
+		rest = rest[:20] + "..."
+	}
+	return rest
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// accept consumes tok if the input continues with it.
+func (p *parser) accept(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (*Expr, error) {
+	return p.parseChain("||", p.parseAnd)
+}
+
+func (p *parser) parseAnd() (*Expr, error) {
+	return p.parseChain("&&", p.parsePrimary)
+}
+
+// parseChain parses operands joined by op into one flat node.
+func (p *parser) parseChain(op string, operand func() (*Expr, error)) (*Expr, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{first}
+	for p.accept(op) {
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, next)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return &Expr{Op: op, Args: args}, nil
+}
+
+func (p *parser) parsePrimary() (*Expr, error) {
+	if p.accept("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return e, nil
+	}
+	cond, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{Cond: cond}, nil
+}
+
+// quoteMetric writes a metric name the way ParseExpression reads it back,
+// quoting it when it contains a delimiter.
+func quoteMetric(name string) string {
+	for i := 0; i < len(name); i++ {
+		if isDelimiter(name[i]) || name[i] == '"' {
+			return strconv.Quote(name)
+		}
+	}
+	return name
+}
+
+// isDelimiter reports whether c ends a metric name, number or unit.
+func isDelimiter(c byte) bool {
+	return strings.IndexByte(" \t<>=!()&|", c) >= 0
+}
+
+// scan consumes bytes for as long as keep accepts them.
+func (p *parser) scan(keep func(c byte) bool) string {
+	start := p.pos
+	for p.pos < len(p.src) && keep(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) parseCondition() (*Condition, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf("expected a condition")
+	}
+	var metric string
+	if p.src[p.pos] == '"' {
+		quoted, err := strconv.QuotedPrefix(p.src[p.pos:])
+		if err != nil {
+			return nil, p.errorf("unterminated quoted metric name")
+		}
+		metric, _ = strconv.Unquote(quoted)
+		p.pos += len(quoted)
+	} else {
+		metric = p.scan(func(c byte) bool { return !isDelimiter(c) })
+	}
+	if metric == "" {
+		return nil, p.errorf("expected a metric name, got %q", p.rest())
+	}
+
+	p.skipSpace()
+	operator := p.scan(func(c byte) bool { return strings.IndexByte("<>=!", c) >= 0 })
+	if operator != ">" && operator != "<" && operator != "==" {
+		if operator == "" && p.pos < len(p.src) && !isDelimiter(p.src[p.pos]) {
+			return nil, p.errorf("expected an operator after %s, quote metric names containing spaces or ()&|<>=! as in \"Disk:/mnt/My Data\" > 90", metric)
+		}
+		if operator == "" {
+			return nil, p.errorf("expected an operator after %s", metric)
+		}
+		return nil, p.errorf("unknown operator %q, use >, < or ==", operator)
+	}
+
+	p.skipSpace()
+	raw := p.scan(func(c byte) bool { return c == '-' || c == '.' || (c >= '0' && c <= '9') })
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, p.errorf("expected a number after %s %s", metric, operator)
+	}
+
+	return &Condition{Metric: metric, Operator: operator, Threshold: threshold, Unit: p.parseUnit()}, nil
+}
+
+// parseUnit consumes an optional threshold unit such as "%" or "MB/s".
+func (p *parser) parseUnit() string {
+	save := p.pos
+	p.skipSpace()
+	rest := p.src[p.pos:]
+	for _, unit := range expressionUnits() {
+		if !strings.HasPrefix(rest, unit) {
+			continue
+		}
+		if len(rest) > len(unit) && !isDelimiter(rest[len(unit)]) {
+			continue
+		}
+		p.pos += len(unit)
+		return unit
+	}
+	p.pos = save
+	return ""
+}
+
+// expressionUnits lists the units used in the catalog, longest first so that
+// "MB/s" wins over "MB". Plain numbers are written without a unit.
+func expressionUnits() []string {
+	seen := make(map[string]bool)
+	var units []string
+	for _, m := range metrics.Catalog {
+		for _, u := range m.Units {
+			if u != metrics.UnitNone && !seen[u] {
+				seen[u] = true
+				units = append(units, u)
+			}
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return len(units[i]) > len(units[j]) })
+	return units
+}
+
+// Conditions lists the comparisons of the expression from left to right.
+func (e *Expr) Conditions() []*Condition {
+	if e.Cond != nil {
+		return []*Condition{e.Cond}
+	}
+	var conds []*Condition
+	for _, arg := range e.Args {
+		conds = append(conds, arg.Conditions()...)
+	}
+	return conds
+}
+
+// Eval reports whether the expression holds for a sample. Operands are
+// evaluated left to right and stop as soon as the result is known; ok is false
+// when a metric needed to decide is missing from the sample.
+func (e *Expr) Eval(stats *metrics.SystemStats) (holds, ok bool) {
+	if e.Cond != nil {
+		value, ok := stats.Value(e.Cond.Metric, e.Cond.Unit)
+		if !ok {
+			return false, false
+		}
+		return compare(value, e.Cond.Operator, e.Cond.Threshold), true
+	}
+	for _, arg := range e.Args {
+		holds, ok := arg.Eval(stats)
+		if !ok {
+			return false, false
+		}
+		if holds == (e.Op == "||") {
+			return holds, true
+		}
+	}
+	return e.Op == "&&", true
+}
+
+// Values lists the current value of every metric in the expression, e.g.
+// "CPU 95.00%, RAM 85.00%", for logs and notifications.
+func (e *Expr) Values(stats *metrics.SystemStats) string {
+	var parts []string
+	for _, c := range e.Conditions() {
+		value, ok := stats.Value(c.Metric, c.Unit)
+		if !ok {
+			parts = append(parts, c.Metric+" n/a")
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %.2f%s", c.Metric, value, c.unitSuffix()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (c *Condition) unitSuffix() string {
+	unit := c.Unit
+	if unit == "" {
+		if m, ok := metrics.LookupMetric(c.Metric); ok {
+			unit = m.Units[0]
+		}
+	}
+	return metrics.UnitSuffix(unit)
+}
+
+func (c *Condition) String() string {
+	return fmt.Sprintf("%s %s %s%s", quoteMetric(c.Metric), c.Operator, strconv.FormatFloat(c.Threshold, 'f', -1, 64), c.unitSuffix())
+}
+
+// Terms splits the expression into its display pieces. Nested combinations are
+// always parenthesized so the grouping is visible without knowing precedence.
+func (e *Expr) Terms() []Term {
+	if e.Cond != nil {
+		return []Term{{Text: e.Cond.String()}}
+	}
+	var terms []Term
+	for i, arg := range e.Args {
+		if i > 0 {
+			terms = append(terms, Term{Text: e.Op, Connective: true})
+		}
+		if arg.Cond != nil {
+			terms = append(terms, arg.Terms()...)
+			continue
+		}
+		terms = append(terms, Term{Text: "("})
+		terms = append(terms, arg.Terms()...)
+		terms = append(terms, Term{Text: ")"})
+	}
+	return terms
+}
+
+// String is the canonical form the expression is stored in.
+func (e *Expr) String() string {
+	var b strings.Builder
+	prev := "("
+	for _, t := range e.Terms() {
+		if prev != "(" && t.Text != ")" {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.Text)
+		prev = t.Text
+	}
+	return b.String()
+}
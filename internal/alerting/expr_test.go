@@ -0,0 +1,128 @@
+package alerting
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/erysngl/zerostat/internal/metrics"
+)
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "expected a condition"},
+		{"CPU", "expected an operator after CPU"},
+		{"CPU >= 90", `unknown operator ">="`},
+		{"CPU > ", "expected a number after CPU >"},
+		{"CPU > high", "expected a number after CPU >"},
+		{"CPU > 90 &&", "expected a condition"},
+		{"CPU > 90 & RAM > 80", `unexpected "& RAM > 80"`},
+		{"(CPU > 90", "missing )"},
+		{"CPU > 90)", `unexpected ")"`},
+		{"&& CPU > 90", "expected a metric name"},
+		{"Disk:/mnt/My Data > 90", "quote metric names containing spaces"},
+		{`"Disk:/mnt/My Data > 90`, "unterminated quoted metric name"},
+	}
+	for _, tt := range tests {
+		_, err := ParseExpression(tt.src)
+		if err == nil {
+			t.Errorf("ParseExpression(%q) succeeded, want an error containing %q", tt.src, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseExpression(%q) error = %q, want it to contain %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseExpressionString(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"CPU>90", "CPU > 90%"},
+		{"CPU > -5", "CPU > -5%"},
+		{"((CPU > 90))", "CPU > 90%"},
+		{"Load1 > 4", "Load1 > 4"},
+		{"NetRx > 1.5 MB/s", "NetRx > 1.5 MB/s"},
+		{"RAMAvail < 512 MB", "RAMAvail < 512 MB"},
+		// && binds tighter than ||, and chains of one connective stay flat
+		{"CPU > 90 || RAM > 80 && Swap > 50", "CPU > 90% || (RAM > 80% && Swap > 50%)"},
+		{"CPU > 90 && RAM > 80 || Swap > 50", "(CPU > 90% && RAM > 80%) || Swap > 50%"},
+		{"(CPU > 90 || RAM > 80) && Swap > 50", "(CPU > 90% || RAM > 80%) && Swap > 50%"},
+		{"CPU > 90 && RAM > 80 && Swap > 50", "CPU > 90% && RAM > 80% && Swap > 50%"},
+		{"CPU > 90 && (RAM > 80 && Swap > 50)", "CPU > 90% && (RAM > 80% && Swap > 50%)"},
+		// Quoted names are unquoted when parsed and quoted again only when needed
+		{`"CPU" > 90`, "CPU > 90%"},
+		{`"Disk:/mnt/My Data" > 90 && RAM > 80`, `"Disk:/mnt/My Data" > 90% && RAM > 80%`},
+		{`"Disk:/mnt/a(b)" == 1`, `"Disk:/mnt/a(b)" == 1%`},
+		{`"Disk:/mnt/say \"hi\"" > 90`, `"Disk:/mnt/say \"hi\"" > 90%`},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpression(tt.src)
+		if err != nil {
+			t.Errorf("ParseExpression(%q): %v", tt.src, err)
+			continue
+		}
+		got := e.String()
+		if got != tt.want {
+			t.Errorf("ParseExpression(%q).String() = %q, want %q", tt.src, got, tt.want)
+		}
+
+		// The canonical form is what gets stored, so it has to parse back to itself
+		again, err := ParseExpression(got)
+		if err != nil {
+			t.Errorf("ParseExpression(%q) of the canonical form: %v", got, err)
+			continue
+		}
+		if again.String() != got {
+			t.Errorf("round trip of %q gave %q", got, again.String())
+		}
+	}
+}
+
+func TestParseExpressionQuotedMetric(t *testing.T) {
+	e, err := ParseExpression(`"Disk:/mnt/My Data" > 90 || "Temp:acpitz 1" > 70 °C`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conds := e.Conditions()
+	if len(conds) != 2 {
+		t.Fatalf("got %d conditions, want 2", len(conds))
+	}
+	if conds[0].Metric != "Disk:/mnt/My Data" || conds[0].Threshold != 90 {
+		t.Errorf("first condition = %+v", *conds[0])
+	}
+	if conds[1].Metric != "Temp:acpitz 1" || conds[1].Unit != metrics.UnitCelsius {
+		t.Errorf("second condition = %+v", *conds[1])
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	stats := &metrics.SystemStats{CPUUsage: 95, MemUsage: 50}
+	tests := []struct {
+		src       string
+		holds, ok bool
+	}{
+		{"CPU > 90 && RAM > 80", false, true},
+		{"CPU > 90 || RAM > 80", true, true},
+		{"RAM > 80 || CPU > 90 && RAM < 60", true, true},
+		{"(RAM > 80 || CPU > 90) && RAM > 60", false, true},
+		// A missing metric only matters when it is needed to decide
+		{"RAM > 80 && Temp:missing > 1", false, true},
+		{"CPU > 90 || Temp:missing > 1", true, true},
+		{"Temp:missing > 1 || CPU > 90", false, false},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpression(tt.src)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q): %v", tt.src, err)
+		}
+		holds, ok := e.Eval(stats)
+		if holds != tt.holds || ok != tt.ok {
+			t.Errorf("Eval(%q) = %v, %v, want %v, %v", tt.src, holds, ok, tt.holds, tt.ok)
+		}
+	}
+}
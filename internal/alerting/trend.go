@@ -0,0 +1,124 @@
+package alerting
+
+import (
+	"math"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/metrics"
+)
+
+// Trend operators compare a value derived from the rule's history window
+// (WindowMinutes) instead of the latest sample.
+const (
+	OpRate     = "rate>"  // increase per minute across the window
+	OpDelta    = "delta>" // absolute change against the start of the window
+	OpAvgAbove = "avg>"   // moving average above the threshold
+	OpAvgBelow = "avg<"   // moving average below the threshold
+	OpFullIn   = "full<"  // hours until a percentage reaches 100, by linear regression
+)
+
+// TrendOperators lists the operators evaluated over a history window.
+var TrendOperators = []string{OpRate, OpDelta, OpAvgAbove, OpAvgBelow, OpFullIn}
+
+// UnitHours is the threshold unit of OpFullIn rules.
+const UnitHours = "h"
+
+// IsTrend reports whether operator is evaluated over a history window.
+func IsTrend(operator string) bool {
+	for _, op := range TrendOperators {
+		if op == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// trendInstances lists the per-instance series active trend rules read from the
+// history store, which only records the instances it is asked to.
+func trendInstances(rules []config.AlertRule) []string {
+	var names []string
+	for _, rule := range rules {
+		if !rule.IsActive || rule.Expression != "" || !IsTrend(rule.Operator) {
+			continue
+		}
+		if _, instance := metrics.SplitInstance(rule.MetricType); instance != "" {
+			names = append(names, rule.MetricType)
+		}
+	}
+	return names
+}
+
+// trendValue derives the quantity a trend rule compares against its threshold.
+// ok is false until the history reaches back at least half the window, so a
+// freshly started collector does not extrapolate from a few seconds of data.
+func trendValue(rule config.AlertRule) (float64, bool) {
+	span := time.Duration(rule.WindowMinutes) * time.Minute
+	unit := rule.ThresholdUnit()
+	if rule.Operator == OpFullIn {
+		unit = ""
+	}
+
+	return windowTrend(rule.Operator, metrics.HistoryWindow(rule.MetricType, unit, span), span)
+}
+
+// windowTrend applies a trend operator to a history window covering span.
+func windowTrend(operator string, w metrics.Window, span time.Duration) (float64, bool) {
+	n := len(w.Values)
+	if n < 2 || w.Times[n-1].Sub(w.Times[0]) < span/2 {
+		return 0, false
+	}
+	first, last := w.Values[0], w.Values[n-1]
+	minutes := w.Times[n-1].Sub(w.Times[0]).Minutes()
+
+	switch operator {
+	case OpRate:
+		return (last - first) / minutes, true
+	case OpDelta:
+		return math.Abs(last - first), true
+	case OpAvgAbove, OpAvgBelow:
+		var sum float64
+		for _, v := range w.Values {
+			sum += v
+		}
+		return sum / float64(n), true
+	case OpFullIn:
+		if last >= 100 {
+			return 0, true
+		}
+		slope := regressionSlope(w)
+		if slope <= 0 {
+			// Flat or shrinking, it never fills up
+			return math.Inf(1), true
+		}
+		return (100 - last) / slope / 60, true
+	}
+	return 0, false
+}
+
+// regressionSlope fits a least squares line through the window and returns its
+// slope per minute.
+func regressionSlope(w metrics.Window) float64 {
+	n := float64(len(w.Values))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, v := range w.Values {
+		x := w.Times[i].Sub(w.Times[0]).Minutes()
+		sumX += x
+		sumY += v
+		sumXY += x * v
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// trendViolates applies a trend operator to its derived value.
+func trendViolates(operator string, value, threshold float64) bool {
+	if FiresBelow(operator) {
+		return value < threshold
+	}
+	return value > threshold
+}
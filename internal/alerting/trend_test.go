@@ -0,0 +1,117 @@
+package alerting
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/metrics"
+)
+
+// series builds a window with one sample per minute.
+func series(values ...float64) metrics.Window {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := metrics.Window{Values: values}
+	for i := range values {
+		w.Times = append(w.Times, start.Add(time.Duration(i)*time.Minute))
+	}
+	return w
+}
+
+func TestWindowTrend(t *testing.T) {
+	span := 10 * time.Minute
+	tests := []struct {
+		name     string
+		operator string
+		w        metrics.Window
+		want     float64
+	}{
+		{"rate rising", OpRate, series(10, 12, 14, 16, 18, 20), 2},
+		{"rate falling", OpRate, series(20, 15, 10, 5, 0, -5), -5},
+		{"delta rising", OpDelta, series(40, 45, 50, 55, 60, 70), 30},
+		{"delta falling", OpDelta, series(70, 60, 55, 50, 45, 40), 30},
+		{"avg", OpAvgAbove, series(10, 20, 30, 40, 50, 60), 35},
+		{"avg below", OpAvgBelow, series(5, 5, 5, 5, 5, 5), 5},
+		// 1% a minute from 70% leaves 30 minutes
+		{"full rising", OpFullIn, series(65, 66, 67, 68, 69, 70), 0.5},
+		{"full already", OpFullIn, series(96, 97, 98, 99, 100, 100), 0},
+		{"full flat", OpFullIn, series(80, 80, 80, 80, 80, 80), math.Inf(1)},
+		{"full decreasing", OpFullIn, series(90, 88, 86, 84, 82, 80), math.Inf(1)},
+	}
+	for _, tt := range tests {
+		got, ok := windowTrend(tt.operator, tt.w, span)
+		if !ok {
+			t.Errorf("%s: ok = false", tt.name)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 && !(math.IsInf(got, 1) && math.IsInf(tt.want, 1)) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWindowTrendNeedsHistory(t *testing.T) {
+	span := 10 * time.Minute
+	tests := []struct {
+		name string
+		w    metrics.Window
+	}{
+		{"empty", series()},
+		{"single sample", series(50)},
+		{"under half the window", series(50, 60, 70, 80)},
+	}
+	for _, tt := range tests {
+		if _, ok := windowTrend(OpRate, tt.w, span); ok {
+			t.Errorf("%s: ok = true", tt.name)
+		}
+	}
+	if _, ok := windowTrend(OpRate, series(50, 60, 70, 80, 90, 100), span); !ok {
+		t.Errorf("half the window: ok = false")
+	}
+}
+
+func TestRegressionSlope(t *testing.T) {
+	tests := []struct {
+		name string
+		w    metrics.Window
+		want float64
+	}{
+		{"line", series(1, 3, 5, 7), 2},
+		{"noisy", series(10, 12, 11, 13), 0.8},
+		{"flat", series(4, 4, 4), 0},
+		{"decreasing", series(9, 6, 3), -3},
+		{"one sample", series(5), 0},
+	}
+	for _, tt := range tests {
+		if got := regressionSlope(tt.w); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrendViolates(t *testing.T) {
+	tests := []struct {
+		operator         string
+		value, threshold float64
+		want             bool
+	}{
+		{OpRate, 3, 2, true},
+		{OpRate, -3, 2, false},
+		{OpDelta, 30, 20, true},
+		{OpDelta, 10, 20, false},
+		{OpAvgAbove, 85, 80, true},
+		{OpAvgAbove, 75, 80, false},
+		{OpAvgBelow, 5, 10, true},
+		{OpAvgBelow, 15, 10, false},
+		{OpFullIn, 0.5, 24, true},
+		{OpFullIn, 0, 24, true},
+		{OpFullIn, 48, 24, false},
+		// A flat or decreasing series never fills up
+		{OpFullIn, math.Inf(1), 24, false},
+	}
+	for _, tt := range tests {
+		if got := trendViolates(tt.operator, tt.value, tt.threshold); got != tt.want {
+			t.Errorf("%s %v against %v: got %v, want %v", tt.operator, tt.value, tt.threshold, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+// Package audit keeps an append-only record of privileged actions (process kills,
+// container stops, configuration changes, automation shell commands, logins).
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes
+const (
+	Success = "success"
+	Failure = "failure"
+	Denied  = "denied"
+)
+
+// Actions recorded by the application
+const (
+	ActionLogin          = "auth.login"
+	ActionLockoutClear   = "auth.unlock"
+	ActionTOTPEnable     = "auth.2fa_enable"
+	ActionTOTPDisable    = "auth.2fa_disable"
+	ActionSessionRotate  = "auth.session_rotate"
+	ActionSessionRevoke  = "auth.session_revoke"
+	ActionProcessKill    = "process.kill"
+	ActionContainerStop  = "container.stop"
+	ActionSettingsUpdate = "settings.update"
+	ActionSettingsReload = "settings.reload"
+	ActionRuleCreate     = "rule.create"
+	ActionRuleUpdate     = "rule.update"
+	ActionRuleToggle     = "rule.toggle"
+	ActionRuleDelete     = "rule.delete"
+	ActionTokenCreate    = "token.create"
+	ActionTokenRevoke    = "token.revoke"
+	ActionUserCreate     = "user.create"
+	ActionUserRole       = "user.role"
+	ActionUserDelete     = "user.delete"
+	ActionShellExec      = "shell.exec"
+)
+
+// Actions lists every action in display order, for filter menus.
+var Actions = []string{
+	ActionLogin, ActionLockoutClear, ActionTOTPEnable, ActionTOTPDisable,
+	ActionSessionRotate, ActionSessionRevoke,
+	ActionProcessKill, ActionContainerStop, ActionSettingsUpdate, ActionSettingsReload,
+	ActionRuleCreate, ActionRuleUpdate, ActionRuleToggle, ActionRuleDelete,
+	ActionTokenCreate, ActionTokenRevoke, ActionUserCreate, ActionUserRole,
+	ActionUserDelete, ActionShellExec,
+}
+
+// SystemUser is the actor for actions the application takes on its own.
+const SystemUser = "system"
+
+// Entry is one audit record, stored as a single JSON line.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	IP      string    `json:"ip,omitempty"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target,omitempty"`
+	Outcome string    `json:"outcome"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// maxTail caps the entries Query serves from memory. Past it Query reads the log.
+const maxTail = 10000
+
+var (
+	mu        sync.Mutex
+	file      *os.File
+	path      string
+	retention time.Duration
+	lastPrune time.Time
+	tail      []Entry // the newest entries, oldest first
+	complete  bool    // tail holds every entry of the log
+)
+
+// Init opens (creating if needed) the audit log for appending after dropping the
+// entries older than keep (0 keeps all).
+func Init(logPath string, keep time.Duration) error {
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+		file = nil
+	}
+	path, retention = logPath, keep
+	prune(time.Now())
+
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	file = f
+	return nil
+}
+
+// SetRetention replaces how long entries are kept, applied on the next prune.
+func SetRetention(keep time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	retention = keep
+	lastPrune = time.Time{}
+}
+
+// Record appends an entry. Failures are logged but never block the audited action.
+func Record(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.User == "" {
+		e.User = SystemUser
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[AUDIT] Failed to encode entry: %v", err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		log.Printf("[AUDIT] %s", line)
+		return
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Printf("[AUDIT] Failed to write entry: %v", err)
+		return
+	}
+
+	tail = append(tail, e)
+	if len(tail) > maxTail {
+		tail = append([]Entry(nil), tail[len(tail)-maxTail/2:]...)
+		complete = false
+	}
+	if e.Time.Sub(lastPrune) >= 24*time.Hour {
+		prune(e.Time)
+	}
+}
+
+// prune rewrites the log without the entries older than the retention, the way
+// the metrics store drops expired day files, and reloads the tail from what is
+// left. Callers hold mu.
+func prune(now time.Time) {
+	lastPrune = now
+	var cutoff time.Time
+	if retention > 0 {
+		cutoff = now.Add(-retention)
+	}
+
+	reopen := file != nil
+	if reopen {
+		file.Close()
+		file = nil
+	}
+	kept, total, err := rewrite(path, cutoff)
+	if err != nil {
+		log.Printf("[AUDIT] Failed to prune %s: %v", path, err)
+	}
+	tail, complete = kept, err == nil && len(kept) == total
+	if reopen {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Printf("[AUDIT] Failed to reopen %s: %v", path, err)
+			return
+		}
+		file = f
+	}
+}
+
+// rewrite drops the entries before cutoff from the log, leaving it untouched when
+// there are none, and returns the newest kept entries with the number kept.
+func rewrite(logPath string, cutoff time.Time) ([]Entry, int, error) {
+	in, err := os.Open(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer in.Close()
+	tmp := logPath + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.Remove(tmp) // a no-op once renamed
+
+	var kept []Entry
+	total, dropped := 0, false
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Time.Before(cutoff) {
+			dropped = true
+			continue
+		}
+		w.Write(scanner.Bytes())
+		w.WriteByte('\n')
+		total++
+		kept = append(kept, e)
+		if len(kept) > maxTail {
+			kept = append([]Entry(nil), kept[len(kept)-maxTail/2:]...)
+		}
+	}
+	err = scanner.Err()
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if dropped {
+		if err := os.Rename(tmp, logPath); err != nil {
+			return nil, 0, err
+		}
+	}
+	return kept, total, nil
+}
+
+// Filter selects entries for Query. Empty fields match everything.
+type Filter struct {
+	User    string
+	Action  string
+	Outcome string
+	Text    string // case-insensitive substring of target or detail
+	From    time.Time
+	To      time.Time
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.User != "" && e.User != f.User {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Outcome != "" && e.Outcome != f.Outcome {
+		return false
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && e.Time.After(f.To) {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(e.Target), text) && !strings.Contains(strings.ToLower(e.Detail), text) {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns matching entries newest first, skipping offset and returning at most
+// limit of them (limit <= 0 means all), together with the total number of matches.
+func Query(f Filter, offset, limit int) ([]Entry, int, error) {
+	var matched []Entry
+	mu.Lock()
+	logPath, inMemory := path, complete
+	if inMemory {
+		for _, e := range tail {
+			if f.match(e) {
+				matched = append(matched, e)
+			}
+		}
+	}
+	mu.Unlock()
+	if logPath == "" {
+		return nil, 0, fmt.Errorf("audit log not initialized")
+	}
+
+	if !inMemory {
+		var err error
+		if matched, err = readMatching(logPath, f); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// The file is chronological; present the newest entries first
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+
+	total := len(matched)
+	if offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[offset:]
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total, nil
+}
+
+// readMatching scans the whole log, for when the tail does not hold all of it.
+func readMatching(logPath string, f Filter) ([]Entry, error) {
+	fh, err := os.Open(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+
+	var matched []Entry
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // A torn final line from a crash must not hide the rest
+		}
+		if f.match(e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, scanner.Err()
+}
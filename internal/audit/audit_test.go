@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitPrunesExpiredEntries(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	var lines []string
+	for _, e := range []Entry{
+		{Time: time.Now().Add(-48 * time.Hour), User: "admin", Action: ActionLogin, Outcome: Success, Detail: "expired"},
+		{Time: time.Now().Add(-time.Hour), User: "admin", Action: ActionLogin, Outcome: Failure},
+	} {
+		line, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+	}
+	lines = append(lines, `{"time":"torn`)
+	if err := os.WriteFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Init(logPath, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mu.Lock()
+		file.Close()
+		file, path = nil, ""
+		mu.Unlock()
+	}()
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 1 || strings.Contains(string(data), "expired") {
+		t.Errorf("log after pruning =\n%s\nwant only the recent entry", data)
+	}
+
+	Record(Entry{User: "bob", Action: ActionRuleCreate, Outcome: Success})
+	entries, total, err := Query(Filter{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || entries[0].User != "bob" || entries[1].Outcome != Failure {
+		t.Errorf("Query = %+v, want the new entry before the recent one", entries)
+	}
+}
@@ -1,55 +1,82 @@
 package auth
 
 import (
+	"crypto/subtle"
+	"log"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/gorilla/sessions"
 )
 
-var store *sessions.CookieStore
-
 const sessionName = "zerostat-session"
 
-// Init initializes the cookie store with a random key holding sessions in memory or via env
+// Init loads the session keys, creating them on first start, and the account stores.
 func Init() {
-	secret := os.Getenv("SESSION_SECRET")
-	var key []byte
-
-	if secret != "" {
-		key = []byte(secret)
-	} else {
-		// Use a consistent default static secret to survive docker restarts if none provided
-		key = []byte("zerostat-default-secret-key-32b!") // Exactly 32 bytes
+	if err := loadSessionKeys(); err != nil {
+		log.Fatalf("Failed to load session keys: %v", err)
 	}
 
-	store = sessions.NewCookieStore(key)
-	// Secure configuration for production-ready approach
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   3600 * 24, // 24 hours
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode,
-		// Secure: true, // Should be true if using HTTPS but kept false for local dev without reverse proxy
-	}
+	LoadTokens()
+	LoadUsers()
+	LoadTOTP()
 }
 
-// Login sets the authentication flag in the session.
-func Login(w http.ResponseWriter, r *http.Request) error {
-	session, _ := store.Get(r, sessionName)
+// Login marks the session as authenticated for username with the given role.
+func Login(w http.ResponseWriter, r *http.Request, username, role string) error {
+	session, _ := currentStore().Get(r, sessionName)
 	// Ignore err on Get (could be an invalid or expired cookie from a previous secret) 
 	// Treat it as a fresh session request
 	
 	session.Values["authenticated"] = true
+	session.Values["user"] = username
+	session.Values["role"] = role
+	// A fresh CSRF token per login, so one planted before sign-in is useless
+	if token, err := newCSRFToken(); err == nil {
+		session.Values["csrf"] = token
+	}
+	delete(session.Values, "pending_user")
+	delete(session.Values, "pending_role")
+	delete(session.Values, "pending_at")
 	return session.Save(r, w)
 }
 
+// secondFactorTimeout bounds the time between the password and the TOTP step.
+const secondFactorTimeout = 5 * time.Minute
+
+// BeginSecondFactor records that username passed the password check but still has
+// to present a TOTP or recovery code. The session is not authenticated yet.
+func BeginSecondFactor(w http.ResponseWriter, r *http.Request, username, role string) error {
+	session, _ := currentStore().Get(r, sessionName)
+	delete(session.Values, "authenticated")
+	session.Values["pending_user"] = username
+	session.Values["pending_role"] = role
+	session.Values["pending_at"] = time.Now().Unix()
+	return session.Save(r, w)
+}
+
+// PendingSecondFactor returns the user waiting in the second login step, if any.
+func PendingSecondFactor(r *http.Request) (string, string, bool) {
+	session, err := currentStore().Get(r, sessionName)
+	if err != nil {
+		return "", "", false
+	}
+	username, _ := session.Values["pending_user"].(string)
+	role, _ := session.Values["pending_role"].(string)
+	at, _ := session.Values["pending_at"].(int64)
+	if username == "" || time.Since(time.Unix(at, 0)) > secondFactorTimeout {
+		return "", "", false
+	}
+	return username, role, true
+}
+
 // Logout removes the authentication flag from the session.
 func Logout(w http.ResponseWriter, r *http.Request) error {
-	session, err := store.Get(r, sessionName)
+	session, err := currentStore().Get(r, sessionName)
 	if err != nil {
 		// If cookie is malformed, we still want to create a new empty one to overwrite it
-		session, _ = store.New(r, sessionName)
+		session, _ = currentStore().New(r, sessionName)
 	}
 	session.Options.MaxAge = -1
 	return session.Save(r, w)
@@ -57,7 +84,7 @@ func Logout(w http.ResponseWriter, r *http.Request) error {
 
 // Check verifies if the user holds an active authenticated session.
 func Check(w http.ResponseWriter, r *http.Request) bool {
-	session, err := store.Get(r, sessionName)
+	session, err := currentStore().Get(r, sessionName)
 	if err != nil {
 		// Malformed cookie detected (possibly from secret change or tampering)
 		// Gracefully clear the bad cookie instead of returning false
@@ -68,16 +95,130 @@ func Check(w http.ResponseWriter, r *http.Request) bool {
 		return false
 	}
 	auth, ok := session.Values["authenticated"].(bool)
-	return ok && auth
+	if !ok || !auth {
+		return false
+	}
+	_, _, ok = identity(session)
+	return ok
+}
+
+// identity resolves the user held by a session. Stored users are looked up again so
+// role changes and deletions apply to sessions that are already open.
+func identity(session *sessions.Session) (string, string, bool) {
+	username, _ := session.Values["user"].(string)
+	if username == "" {
+		return "", "", false
+	}
+	if username == BootstrapAdmin {
+		return username, RoleAdmin, true
+	}
+	u, ok := GetUser(username)
+	if !ok {
+		return "", "", false
+	}
+	return u.Username, u.Role, true
+}
+
+// CurrentUser returns the name and role of the session's user, if any.
+func CurrentUser(r *http.Request) (string, string, bool) {
+	session, err := currentStore().Get(r, sessionName)
+	if err != nil {
+		return "", "", false
+	}
+	if auth, _ := session.Values["authenticated"].(bool); !auth {
+		return "", "", false
+	}
+	return identity(session)
+}
+
+// Actor names whoever is behind r for audit purposes: the session user, or
+// "token:<name>" for requests authenticated by an API token.
+func Actor(r *http.Request) string {
+	if token, ok := RequestToken(r); ok {
+		return "token:" + token.Name
+	}
+	if user, _, ok := CurrentUser(r); ok {
+		return user
+	}
+	return "anonymous"
+}
+
+// isAPIClient tells scripted clients apart from browsers so they get a JSON 401
+// instead of a redirect to the login page.
+func isAPIClient(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
 }
 
 // Middleware creates an HTTP handler wrapping protected routes, redirecting unauthenticated requests.
 func Middleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !Check(w, r) {
+			if isAPIClient(r) {
+				writeJSONStatus(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			if r.Header.Get("HX-Request") == "true" {
+				// HTMX would swap the login page into a fragment, ask it to navigate instead
+				w.Header().Set("HX-Redirect", "/login")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
+		ensureCSRFToken(w, r)
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireRole wraps Middleware and CSRFMiddleware and additionally rejects users whose
+// role ranks below role.
+func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return Middleware(CSRFMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		_, have, _ := CurrentUser(r)
+		if !RoleAllows(have, role) {
+			if isAPIClient(r) {
+				writeJSONStatus(w, http.StatusForbidden, "insufficient role")
+				return
+			}
+			http.Error(w, "Forbidden: requires the "+role+" role", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+
+// BearerToken extracts the token of an "Authorization: Bearer <token>" header.
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// TokenMiddleware guards machine endpoints (e.g. Prometheus scraping) with a static
+// bearer token independent of the session cookie. An empty token leaves them open.
+// Named API tokens holding the stats:read scope are accepted as well.
+func TokenMiddleware(token func() string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := token()
+		bearer := BearerToken(r)
+		if expected != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(expected)) != 1 {
+			if t, ok := LookupToken(bearer); ok && t.HasScope(ScopeStatsRead) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="zerostat"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
 		next.ServeHTTP(w, r)
 	}
 }
+
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+)
+
+const (
+	// CSRFHeader is sent by HTMX requests (see hx-headers on <body>)
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField is the hidden form field used by plain HTML forms
+	CSRFField = "csrf_token"
+)
+
+// ensureCSRFToken gives the session a token if it has none yet. Sessions created
+// by Login already carry one; this covers sessions from before CSRF protection.
+func ensureCSRFToken(w http.ResponseWriter, r *http.Request) {
+	session, err := currentStore().Get(r, sessionName)
+	if err != nil {
+		return
+	}
+	if token, _ := session.Values["csrf"].(string); token != "" {
+		return
+	}
+	token, err := newCSRFToken()
+	if err != nil {
+		log.Printf("Error generating CSRF token: %v", err)
+		return
+	}
+	session.Values["csrf"] = token
+	session.Save(r, w)
+}
+
+func newCSRFToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// CSRFToken returns the session's token for embedding in pages.
+func CSRFToken(r *http.Request) string {
+	session, err := currentStore().Get(r, sessionName)
+	if err != nil {
+		return ""
+	}
+	token, _ := session.Values["csrf"].(string)
+	return token
+}
+
+// CSRFMiddleware rejects state-changing requests whose token, taken from the
+// X-CSRF-Token header or the csrf_token form field, does not match the session.
+// Safe methods pass through untouched.
+func CSRFMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		expected := CSRFToken(r)
+		given := r.Header.Get(CSRFHeader)
+		if given == "" {
+			given = r.PostFormValue(CSRFField)
+		}
+		if expected == "" || subtle.ConstantTimeCompare([]byte(given), []byte(expected)) != 1 {
+			log.Printf("[AUTH] Rejected %s %s from %s: invalid CSRF token", r.Method, r.URL.Path, ClientIP(r))
+			if isAPIClient(r) {
+				writeJSONStatus(w, http.StatusForbidden, "invalid or missing CSRF token")
+				return
+			}
+			http.Error(w, "Forbidden: invalid or missing CSRF token. Reload the page and try again.", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Passwords are stored as PBKDF2-HMAC-SHA256 (RFC 8018) in the self-describing form
+//
+//	pbkdf2-sha256$<iterations>$<salt>$<key>
+//
+// with base64 (raw, std alphabet) salt and key. The standard library has everything
+// needed, keeping the binary free of extra crypto dependencies.
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 600000
+	hashSaltLen    = 16
+	hashKeyLen     = 32
+)
+
+func pbkdf2SHA256(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	out := make([]byte, 0, blocks*hashLen)
+	buf := make([]byte, 4)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf, uint32(block))
+		prf.Write(buf)
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
+
+// HashPassword derives a salted hash suitable for storage.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2SHA256([]byte(password), salt, hashIterations, hashKeyLen)
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, hashIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// IsPasswordHash reports whether s looks like a value produced by HashPassword.
+func IsPasswordHash(s string) bool {
+	_, _, _, ok := parseHash(s)
+	return ok
+}
+
+func parseHash(s string) (iter int, salt, key []byte, ok bool) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return 0, nil, nil, false
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter < 1 {
+		return 0, nil, nil, false
+	}
+	salt, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, false
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, false
+	}
+	return iter, salt, key, true
+}
+
+// VerifyPassword checks password against a stored hash in constant time.
+func VerifyPassword(hash, password string) bool {
+	iter, salt, key, ok := parseHash(hash)
+	if !ok {
+		return false
+	}
+	derived := pbkdf2SHA256([]byte(password), salt, iter, len(key))
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
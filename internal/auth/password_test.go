@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// Published PBKDF2-HMAC-SHA256 test vectors
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		want           string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40,
+			"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("%s/%s/%d: got %s, want %s", tt.password, tt.salt, tt.iter, got, tt.want)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") || !IsPasswordHash(hash) {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+	if !VerifyPassword(hash, "correct horse") {
+		t.Error("password does not verify against its hash")
+	}
+	if VerifyPassword(hash, "correct horse ") {
+		t.Error("wrong password verifies")
+	}
+
+	other, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("two hashes of one password share a salt")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	// Hashes record their iteration count, so ones made with another cost still verify
+	const cheap = "pbkdf2-sha256$1$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs"
+	tests := []struct {
+		hash, password string
+		want           bool
+	}{
+		{cheap, "password", true},
+		{cheap, "Password", false},
+		{cheap, "", false},
+		{"password", "password", false}, // plaintext is never compared
+		{"", "", false},
+		{"bcrypt$1$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs", "password", false},
+		{"pbkdf2-sha256$0$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs", "password", false},
+		{"pbkdf2-sha256$1$c2FsdA$", "password", false},
+		{"pbkdf2-sha256$1$!!$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs", "password", false},
+	}
+	for _, tt := range tests {
+		if got := VerifyPassword(tt.hash, tt.password); got != tt.want {
+			t.Errorf("VerifyPassword(%q, %q) = %v, want %v", tt.hash, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestIsPasswordHash(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		// A plaintext password left in .env is migrated, a hash is kept
+		{"admin", false},
+		{"pbkdf2-sha256$600000", false},
+		{"my$secret$pass$word", false},
+		{"pbkdf2-sha256$1$c2FsdA$Eg+2z/z4syxD5yJSVsT4N6hlSMkszDVICAWYfLcL4Xs", true},
+	}
+	for _, tt := range tests {
+		if got := IsPasswordHash(tt.value); got != tt.want {
+			t.Errorf("IsPasswordHash(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lockout describes a client that is temporarily barred from logging in.
+type Lockout struct {
+	IP    string
+	Until time.Time
+}
+
+// loginLimiter tracks failed logins per client IP in a sliding window. Reaching
+// maxFailures within window locks the client out for lockout; old failures decay
+// out of the window on their own.
+type loginLimiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+	failures    map[string][]time.Time
+	lockedUntil map[string]time.Time
+}
+
+var (
+	limiter = &loginLimiter{
+		maxFailures: 5,
+		window:      15 * time.Minute,
+		lockout:     15 * time.Minute,
+		failures:    make(map[string][]time.Time),
+		lockedUntil: make(map[string]time.Time),
+	}
+
+	proxyMu            sync.RWMutex
+	trustedProxyHeader string
+)
+
+// ConfigureLoginLimit sets the lockout policy. Non-positive values keep the defaults.
+func ConfigureLoginLimit(maxFailures int, window, lockout time.Duration) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if maxFailures > 0 {
+		limiter.maxFailures = maxFailures
+	}
+	if window > 0 {
+		limiter.window = window
+	}
+	if lockout > 0 {
+		limiter.lockout = lockout
+	}
+}
+
+// SetTrustedProxyHeader names the header (e.g. X-Forwarded-For or X-Real-IP) a reverse
+// proxy uses to pass the client address. Empty means the TCP peer is the client.
+func SetTrustedProxyHeader(header string) {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	trustedProxyHeader = header
+}
+
+// ClientIP returns the address login limits and audit entries are keyed by. Only
+// the last hop of the trusted header is used: a client can prepend anything to
+// X-Forwarded-For, but the value appended by our own proxy is reliable.
+func ClientIP(r *http.Request) string {
+	proxyMu.RLock()
+	header := trustedProxyHeader
+	proxyMu.RUnlock()
+
+	if header != "" {
+		if value := r.Header.Get(header); value != "" {
+			hops := strings.Split(value, ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// prune drops expired lockouts and failures outside the window; callers hold mu.
+func (l *loginLimiter) prune(now time.Time) {
+	for ip, until := range l.lockedUntil {
+		if !now.Before(until) {
+			delete(l.lockedUntil, ip)
+		}
+	}
+	cutoff := now.Add(-l.window)
+	for ip, times := range l.failures {
+		kept := times[:0]
+		for _, t := range times {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(l.failures, ip)
+		} else {
+			l.failures[ip] = kept
+		}
+	}
+}
+
+// LoginLockedFor reports how long ip must still wait before it may try to log in.
+func LoginLockedFor(ip string) (time.Duration, bool) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	until, ok := limiter.lockedUntil[ip]
+	if !ok {
+		return 0, false
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(limiter.lockedUntil, ip)
+		return 0, false
+	}
+	return remaining, true
+}
+
+// RecordLoginFailure counts a failed attempt and reports whether it locked ip out.
+func RecordLoginFailure(ip string) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	now := time.Now()
+	limiter.prune(now)
+
+	limiter.failures[ip] = append(limiter.failures[ip], now)
+	if len(limiter.failures[ip]) < limiter.maxFailures {
+		return false
+	}
+	delete(limiter.failures, ip)
+	limiter.lockedUntil[ip] = now.Add(limiter.lockout)
+	return true
+}
+
+// RecordLoginSuccess forgets earlier failures of ip.
+func RecordLoginSuccess(ip string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	delete(limiter.failures, ip)
+}
+
+// Lockouts lists the clients currently locked out, soonest to expire first.
+func Lockouts() []Lockout {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.prune(time.Now())
+
+	out := make([]Lockout, 0, len(limiter.lockedUntil))
+	for ip, until := range limiter.lockedUntil {
+		out = append(out, Lockout{IP: ip, Until: until})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Until.Before(out[j].Until) })
+	return out
+}
+
+// ClearLockout lifts a lockout early.
+func ClearLockout(ip string) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	_, ok := limiter.lockedUntil[ip]
+	delete(limiter.lockedUntil, ip)
+	delete(limiter.failures, ip)
+	return ok
+}
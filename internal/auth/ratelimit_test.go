@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useLimiter swaps in a fresh limiter for the duration of a test.
+func useLimiter(t *testing.T, maxFailures int, window, lockout time.Duration) {
+	saved := limiter
+	limiter = &loginLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+		failures:    make(map[string][]time.Time),
+		lockedUntil: make(map[string]time.Time),
+	}
+	t.Cleanup(func() { limiter = saved })
+}
+
+func TestLoginLockout(t *testing.T) {
+	useLimiter(t, 3, time.Minute, time.Hour)
+
+	for i := 1; i < 3; i++ {
+		if RecordLoginFailure("10.0.0.1") {
+			t.Fatalf("failure %d locked the client out", i)
+		}
+	}
+	if !RecordLoginFailure("10.0.0.1") {
+		t.Fatal("third failure did not lock the client out")
+	}
+
+	remaining, locked := LoginLockedFor("10.0.0.1")
+	if !locked || remaining <= 59*time.Minute || remaining > time.Hour {
+		t.Errorf("LoginLockedFor = %v, %v, want about an hour", remaining, locked)
+	}
+	if _, locked := LoginLockedFor("10.0.0.2"); locked {
+		t.Error("another client is locked out")
+	}
+	if got := Lockouts(); len(got) != 1 || got[0].IP != "10.0.0.1" {
+		t.Errorf("Lockouts = %v", got)
+	}
+}
+
+func TestLoginLockoutExpires(t *testing.T) {
+	useLimiter(t, 2, time.Minute, time.Hour)
+
+	RecordLoginFailure("10.0.0.1")
+	RecordLoginFailure("10.0.0.1")
+	if _, locked := LoginLockedFor("10.0.0.1"); !locked {
+		t.Fatal("client is not locked out")
+	}
+
+	limiter.lockedUntil["10.0.0.1"] = time.Now().Add(-time.Second)
+	if _, locked := LoginLockedFor("10.0.0.1"); locked {
+		t.Error("expired lockout still applies")
+	}
+	if got := Lockouts(); len(got) != 0 {
+		t.Errorf("Lockouts = %v, want none", got)
+	}
+	// The failures that caused the lockout do not count again
+	if RecordLoginFailure("10.0.0.1") {
+		t.Error("first failure after the lockout locked the client out again")
+	}
+}
+
+func TestLoginFailuresDecay(t *testing.T) {
+	useLimiter(t, 3, time.Minute, time.Hour)
+
+	old := time.Now().Add(-2 * time.Minute)
+	limiter.failures["10.0.0.1"] = []time.Time{old, old}
+	if RecordLoginFailure("10.0.0.1") {
+		t.Error("failures outside the window still count")
+	}
+	if got := len(limiter.failures["10.0.0.1"]); got != 1 {
+		t.Errorf("%d failures tracked, want 1", got)
+	}
+}
+
+func TestLoginSuccessAndClear(t *testing.T) {
+	useLimiter(t, 2, time.Minute, time.Hour)
+
+	RecordLoginFailure("10.0.0.1")
+	RecordLoginSuccess("10.0.0.1")
+	if RecordLoginFailure("10.0.0.1") {
+		t.Error("failure before a successful login still counts")
+	}
+
+	RecordLoginFailure("10.0.0.1")
+	if !ClearLockout("10.0.0.1") {
+		t.Fatal("ClearLockout found no lockout")
+	}
+	if _, locked := LoginLockedFor("10.0.0.1"); locked {
+		t.Error("cleared lockout still applies")
+	}
+	if ClearLockout("10.0.0.1") {
+		t.Error("ClearLockout cleared a lockout twice")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxyHeader("") })
+	tests := []struct {
+		header, value string
+		want          string
+	}{
+		{"", "", "192.0.2.1"},
+		{"", "203.0.113.9", "192.0.2.1"}, // not trusted without a configured header
+		{"X-Forwarded-For", "203.0.113.9", "203.0.113.9"},
+		{"X-Forwarded-For", "198.51.100.7, 203.0.113.9", "203.0.113.9"},
+		{"X-Forwarded-For", "", "192.0.2.1"},
+		{"X-Real-IP", "203.0.113.9", "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		SetTrustedProxyHeader(tt.header)
+		r := httptest.NewRequest("POST", "/login", nil)
+		r.RemoteAddr = "192.0.2.1:51234"
+		if tt.value != "" {
+			r.Header.Set("X-Forwarded-For", tt.value)
+			r.Header.Set("X-Real-IP", tt.value)
+		}
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("header %q, value %q: got %s, want %s", tt.header, tt.value, got, tt.want)
+		}
+	}
+}
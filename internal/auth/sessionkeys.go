@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// retiredSessionKeys is how many previous key pairs keep verifying existing
+// cookies after a rotation. New cookies are always signed with the newest pair.
+const retiredSessionKeys = 2
+
+// sessionMaxAge bounds both the cookie lifetime and how long a signed value is accepted.
+const sessionMaxAge = 3600 * 24 // 24 hours
+
+// sessionKey is one signing (HMAC-SHA256) and encryption (AES-256) key pair.
+type sessionKey struct {
+	HashKey   []byte    `json:"hash_key"`
+	BlockKey  []byte    `json:"block_key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	sessionKeysMu sync.Mutex
+	sessionKeys   []sessionKey // newest first
+
+	storeMu       sync.RWMutex
+	store         *sessions.CookieStore
+	secureCookies bool
+)
+
+func sessionKeysPath() string {
+	return filepath.Join("data", "session_keys.json")
+}
+
+func newSessionKey() (sessionKey, error) {
+	k := sessionKey{HashKey: make([]byte, 64), BlockKey: make([]byte, 32), CreatedAt: time.Now()}
+	if _, err := rand.Read(k.HashKey); err != nil {
+		return sessionKey{}, err
+	}
+	if _, err := rand.Read(k.BlockKey); err != nil {
+		return sessionKey{}, err
+	}
+	return k, nil
+}
+
+// loadSessionKeys reads the persisted key pairs, generating the first one on a
+// fresh install.
+func loadSessionKeys() error {
+	sessionKeysMu.Lock()
+	defer sessionKeysMu.Unlock()
+
+	fileBytes, err := os.ReadFile(sessionKeysPath())
+	if err == nil {
+		var loaded []sessionKey
+		if err := json.Unmarshal(fileBytes, &loaded); err != nil {
+			return fmt.Errorf("parsing %s: %w", sessionKeysPath(), err)
+		}
+		if len(loaded) > 0 {
+			sessionKeys = loaded
+			applySessionKeys()
+			return nil
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	k, err := newSessionKey()
+	if err != nil {
+		return err
+	}
+	sessionKeys = []sessionKey{k}
+	if err := saveSessionKeys(); err != nil {
+		return err
+	}
+	applySessionKeys()
+	log.Printf("Generated a new session key in %s", sessionKeysPath())
+	return nil
+}
+
+// saveSessionKeys persists the key pairs; callers must hold sessionKeysMu.
+func saveSessionKeys() error {
+	if err := os.MkdirAll("data", 0755); err != nil {
+		return err
+	}
+	fileBytes, err := json.MarshalIndent(sessionKeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(sessionKeysPath(), fileBytes, 0600)
+}
+
+// applySessionKeys swaps in a cookie store built from the current key pairs;
+// callers must hold sessionKeysMu.
+func applySessionKeys() {
+	pairs := make([][]byte, 0, 2*len(sessionKeys))
+	for _, k := range sessionKeys {
+		pairs = append(pairs, k.HashKey, k.BlockKey)
+	}
+	s := sessions.NewCookieStore(pairs...)
+	s.MaxAge(sessionMaxAge)
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	// Secure configuration for production-ready approach
+	s.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   sessionMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   secureCookies, // set once the server itself terminates TLS
+	}
+	store = s
+}
+
+// SetSecureCookies marks session cookies Secure, so browsers only send them over HTTPS.
+func SetSecureCookies(secure bool) {
+	sessionKeysMu.Lock()
+	defer sessionKeysMu.Unlock()
+	storeMu.Lock()
+	secureCookies = secure
+	storeMu.Unlock()
+	if len(sessionKeys) > 0 {
+		applySessionKeys()
+	}
+}
+
+// currentStore returns the cookie store for the active key pairs.
+func currentStore() *sessions.CookieStore {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return store
+}
+
+// RotateSessionKeys starts signing sessions with a new key pair. Existing sessions
+// stay valid, as the previous pairs are still accepted for verification.
+func RotateSessionKeys() error {
+	k, err := newSessionKey()
+	if err != nil {
+		return err
+	}
+
+	sessionKeysMu.Lock()
+	defer sessionKeysMu.Unlock()
+	previous := sessionKeys
+	sessionKeys = append([]sessionKey{k}, sessionKeys...)
+	if len(sessionKeys) > retiredSessionKeys+1 {
+		sessionKeys = sessionKeys[:retiredSessionKeys+1]
+	}
+	if err := saveSessionKeys(); err != nil {
+		sessionKeys = previous
+		return err
+	}
+	applySessionKeys()
+	return nil
+}
+
+// RevokeAllSessions replaces every key pair, which signs out all sessions at once,
+// including the caller's.
+func RevokeAllSessions() error {
+	k, err := newSessionKey()
+	if err != nil {
+		return err
+	}
+
+	sessionKeysMu.Lock()
+	defer sessionKeysMu.Unlock()
+	previous := sessionKeys
+	sessionKeys = []sessionKey{k}
+	if err := saveSessionKeys(); err != nil {
+		sessionKeys = previous
+		return err
+	}
+	applySessionKeys()
+	return nil
+}
+
+// SessionKeyInfo describes the key pairs for display, without the key material.
+type SessionKeyInfo struct {
+	CreatedAt time.Time
+	Active    bool
+}
+
+// SessionKeys lists the key pairs, newest (active) first.
+func SessionKeys() []SessionKeyInfo {
+	sessionKeysMu.Lock()
+	defer sessionKeysMu.Unlock()
+	out := make([]SessionKeyInfo, len(sessionKeys))
+	for i, k := range sessionKeys {
+		out[i] = SessionKeyInfo{CreatedAt: k.CreatedAt, Active: i == 0}
+	}
+	return out
+}
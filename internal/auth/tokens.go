@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// API token scopes
+const (
+	ScopeStatsRead      = "stats:read"
+	ScopeProcessControl = "process:control"
+	ScopeRulesManage    = "rules:manage"
+)
+
+// Scopes lists every scope a token can be granted, in display order.
+var Scopes = []string{ScopeStatsRead, ScopeProcessControl, ScopeRulesManage}
+
+const tokenPrefix = "zst_"
+
+// APIToken is a named, revocable bearer token. Only the SHA-256 of the secret is
+// kept; the plaintext is shown once at creation.
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Hash       string     `json:"hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	tokensMu sync.RWMutex
+	tokens   []APIToken
+)
+
+func tokensPath() string {
+	return filepath.Join("data", "tokens.json")
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadTokens reads the token store from disk.
+func LoadTokens() {
+	fileBytes, err := os.ReadFile(tokensPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: failed to read %s: %v", tokensPath(), err)
+		}
+		return
+	}
+
+	var loaded []APIToken
+	if err := json.Unmarshal(fileBytes, &loaded); err != nil {
+		log.Printf("Warning: failed to parse %s: %v", tokensPath(), err)
+		return
+	}
+
+	tokensMu.Lock()
+	tokens = loaded
+	tokensMu.Unlock()
+	log.Printf("Loaded %d API tokens from disk", len(loaded))
+}
+
+// saveTokens persists the store; callers must hold tokensMu.
+func saveTokens() error {
+	if err := os.MkdirAll("data", 0755); err != nil {
+		return err
+	}
+	fileBytes, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tokensPath(), fileBytes, 0600)
+}
+
+// ListTokens returns a copy of all tokens.
+func ListTokens() []APIToken {
+	tokensMu.RLock()
+	defer tokensMu.RUnlock()
+	out := make([]APIToken, len(tokens))
+	copy(out, tokens)
+	return out
+}
+
+// CreateToken generates a new token and returns its plaintext secret.
+func CreateToken(name string, scopes []string) (string, APIToken, error) {
+	if name == "" {
+		return "", APIToken{}, fmt.Errorf("token name is required")
+	}
+	var granted []string
+	for _, s := range Scopes {
+		for _, want := range scopes {
+			if s == want {
+				granted = append(granted, s)
+				break
+			}
+		}
+	}
+	if len(granted) == 0 {
+		return "", APIToken{}, fmt.Errorf("at least one scope is required")
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", APIToken{}, err
+	}
+	secret := tokenPrefix + hex.EncodeToString(raw)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", APIToken{}, err
+	}
+
+	token := APIToken{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Hint:      secret[:len(tokenPrefix)+6],
+		Hash:      hashToken(secret),
+		Scopes:    granted,
+		CreatedAt: time.Now(),
+	}
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	tokens = append(tokens, token)
+	if err := saveTokens(); err != nil {
+		tokens = tokens[:len(tokens)-1]
+		return "", APIToken{}, err
+	}
+	return secret, token, nil
+}
+
+// RevokeToken deletes a token by ID.
+func RevokeToken(id string) bool {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	for i, t := range tokens {
+		if t.ID == id {
+			tokens = append(tokens[:i:i], tokens[i+1:]...)
+			if err := saveTokens(); err != nil {
+				log.Printf("Error writing tokens to disk: %v", err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// LookupToken resolves a plaintext bearer secret to its token.
+func LookupToken(secret string) (APIToken, bool) {
+	if secret == "" {
+		return APIToken{}, false
+	}
+	hash := []byte(hashToken(secret))
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+	for i, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			// Only persist last-use bookkeeping once a minute to avoid a write per request
+			now := time.Now()
+			persist := t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > time.Minute
+			tokens[i].LastUsedAt = &now
+			if persist {
+				if err := saveTokens(); err != nil {
+					log.Printf("Error writing tokens to disk: %v", err)
+				}
+			}
+			return tokens[i], true
+		}
+	}
+	return APIToken{}, false
+}
+
+type tokenContextKey struct{}
+
+// RequestToken returns the API token that authenticated r via APIMiddleware.
+func RequestToken(r *http.Request) (APIToken, bool) {
+	token, ok := r.Context().Value(tokenContextKey{}).(APIToken)
+	return token, ok
+}
+
+// writeJSONStatus answers API clients with a small JSON error body.
+func writeJSONStatus(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// APIMiddleware guards the JSON API with named API tokens. Safe methods (GET, HEAD)
+// need readScope or writeScope, everything else needs writeScope.
+func APIMiddleware(readScope, writeScope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := LookupToken(BearerToken(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="zerostat"`)
+			writeJSONStatus(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		allowed := token.HasScope(writeScope)
+		if !allowed && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			allowed = token.HasScope(readScope)
+		}
+		if !allowed {
+			writeJSONStatus(w, http.StatusForbidden, "token lacks the required scope")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+	}
+}
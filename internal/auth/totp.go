@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TOTP parameters (RFC 6238 defaults understood by every authenticator app)
+const (
+	totpPeriod        = 30
+	totpDigits        = 6
+	totpSkew          = 1 // accept codes one step either side of now
+	totpIssuer        = "ZeroStat"
+	recoveryCodeCount = 10
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpEnrollment is the second factor state of one user. Secret is kept as is because
+// codes are derived from it; recovery codes are stored as SHA-256 hashes only.
+type totpEnrollment struct {
+	Secret        string     `json:"secret"`
+	Enabled       bool       `json:"enabled"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+	LastStep      int64      `json:"last_step"`
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"`
+}
+
+var (
+	totpMu  sync.Mutex
+	totpAll = map[string]*totpEnrollment{}
+)
+
+func totpPath() string {
+	return filepath.Join("data", "totp.json")
+}
+
+// LoadTOTP reads second factor enrollments from disk.
+func LoadTOTP() {
+	fileBytes, err := os.ReadFile(totpPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: failed to read %s: %v", totpPath(), err)
+		}
+		return
+	}
+
+	loaded := map[string]*totpEnrollment{}
+	if err := json.Unmarshal(fileBytes, &loaded); err != nil {
+		log.Printf("Warning: failed to parse %s: %v", totpPath(), err)
+		return
+	}
+
+	totpMu.Lock()
+	totpAll = loaded
+	totpMu.Unlock()
+}
+
+// saveTOTP persists enrollments; callers must hold totpMu.
+func saveTOTP() error {
+	if err := os.MkdirAll("data", 0755); err != nil {
+		return err
+	}
+	fileBytes, err := json.MarshalIndent(totpAll, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(totpPath(), fileBytes, 0600)
+}
+
+// totpCode computes the HOTP value (RFC 4226) for counter.
+func totpCode(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// matchTOTP returns the time step code is valid for, within the allowed skew.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := b32.DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, uint64(step))), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	hashed := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 6)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(b32.EncodeToString(raw)) // 10 characters
+		plain = append(plain, code[:5]+"-"+code[5:])
+		hashed = append(hashed, hashRecoveryCode(code))
+	}
+	return plain, hashed, nil
+}
+
+// TOTPEnabled reports whether username must pass a second factor at login.
+func TOTPEnabled(username string) bool {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	e, ok := totpAll[username]
+	return ok && e.Enabled
+}
+
+// RecoveryCodesLeft counts the unused recovery codes of username.
+func RecoveryCodesLeft(username string) int {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	if e, ok := totpAll[username]; ok {
+		return len(e.RecoveryCodes)
+	}
+	return 0
+}
+
+// BeginTOTPEnrollment generates a fresh secret for username that takes effect only
+// once ConfirmTOTPEnrollment has seen a valid code for it. It returns the base32
+// secret and the otpauth:// URI authenticator apps import.
+func BeginTOTPEnrollment(username string) (string, string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret := b32.EncodeToString(raw)
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	if e, ok := totpAll[username]; ok && e.Enabled {
+		return "", "", fmt.Errorf("two-factor authentication is already enabled")
+	}
+	totpAll[username] = &totpEnrollment{Secret: secret}
+	if err := saveTOTP(); err != nil {
+		return "", "", err
+	}
+	return secret, TOTPURI(username, secret), nil
+}
+
+// PendingTOTPSecret returns the secret of an enrollment awaiting confirmation.
+func PendingTOTPSecret(username string) (string, bool) {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	e, ok := totpAll[username]
+	if !ok || e.Enabled {
+		return "", false
+	}
+	return e.Secret, true
+}
+
+// TOTPURI builds the Key URI Format understood by authenticator apps.
+func TOTPURI(username, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + username)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("period", fmt.Sprint(totpPeriod))
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("algorithm", "SHA1")
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// ConfirmTOTPEnrollment enables the pending secret after checking code against it and
+// returns freshly generated recovery codes, shown to the user exactly once.
+func ConfirmTOTPEnrollment(username, code string) ([]string, error) {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	e, ok := totpAll[username]
+	if !ok || e.Enabled {
+		return nil, fmt.Errorf("no pending two-factor enrollment")
+	}
+	step, ok := matchTOTP(e.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, fmt.Errorf("invalid verification code")
+	}
+
+	plain, hashed, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	e.Enabled = true
+	e.EnabledAt = &now
+	e.LastStep = step
+	e.RecoveryCodes = hashed
+	if err := saveTOTP(); err != nil {
+		e.Enabled = false
+		return nil, err
+	}
+	return plain, nil
+}
+
+// DisableTOTP removes the second factor of username.
+func DisableTOTP(username string) bool {
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	if _, ok := totpAll[username]; !ok {
+		return false
+	}
+	delete(totpAll, username)
+	if err := saveTOTP(); err != nil {
+		log.Printf("Error writing %s: %v", totpPath(), err)
+	}
+	return true
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused recovery code.
+// A TOTP code is accepted only once and recovery codes are consumed on use.
+func VerifySecondFactor(username, code string) bool {
+	code = strings.TrimSpace(code)
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	e, ok := totpAll[username]
+	if !ok || !e.Enabled {
+		return false
+	}
+
+	if step, ok := matchTOTP(e.Secret, code, time.Now()); ok {
+		if step <= e.LastStep {
+			return false // replay of an already used code
+		}
+		e.LastStep = step
+		if err := saveTOTP(); err != nil {
+			log.Printf("Error writing %s: %v", totpPath(), err)
+		}
+		return true
+	}
+
+	hash := []byte(hashRecoveryCode(code))
+	for i, stored := range e.RecoveryCodes {
+		if subtle.ConstantTimeCompare(hash, []byte(stored)) == 1 {
+			e.RecoveryCodes = append(e.RecoveryCodes[:i:i], e.RecoveryCodes[i+1:]...)
+			if err := saveTOTP(); err != nil {
+				log.Printf("Error writing %s: %v", totpPath(), err)
+			}
+			return true
+		}
+	}
+	return false
+}
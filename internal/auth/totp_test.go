@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	key, err := b32.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last six digits of the RFC 6238 appendix B values
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, uint64(tt.unix/totpPeriod)); got != tt.want {
+			t.Errorf("at %d: got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTPSkew(t *testing.T) {
+	key, _ := b32.DecodeString(rfcSecret)
+	const step = 1234567890 / totpPeriod
+	code := totpCode(key, step)
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		now := time.Unix((step+offset)*totpPeriod+10, 0)
+		got, ok := matchTOTP(rfcSecret, code, now)
+		if want := offset >= -totpSkew && offset <= totpSkew; ok != want {
+			t.Errorf("%+d steps away: ok = %v, want %v", offset, ok, want)
+			continue
+		}
+		if ok && got != step {
+			t.Errorf("%+d steps away: matched step %d, want %d", offset, got, step)
+		}
+	}
+
+	now := time.Unix(step*totpPeriod, 0)
+	for _, bad := range []string{"", "00592", "0059240", "000000"} {
+		if _, ok := matchTOTP(rfcSecret, bad, now); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+	if _, ok := matchTOTP("not base32!", code, now); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+// enrollTOTP enables rfcSecret for username with the given recovery codes, in a
+// temporary directory so saveTOTP does not touch the real data/.
+func enrollTOTP(t *testing.T, username string, recovery ...string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	saved := totpAll
+	t.Cleanup(func() {
+		totpAll = saved
+		os.Chdir(wd)
+	})
+
+	var hashed []string
+	for _, code := range recovery {
+		hashed = append(hashed, hashRecoveryCode(code))
+	}
+	totpAll = map[string]*totpEnrollment{
+		username: {Secret: rfcSecret, Enabled: true, RecoveryCodes: hashed},
+	}
+}
+
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	enrollTOTP(t, "admin")
+	key, _ := b32.DecodeString(rfcSecret)
+	step := time.Now().Unix() / totpPeriod
+
+	previous := totpCode(key, uint64(step-1))
+	if !VerifySecondFactor("admin", previous) {
+		t.Fatal("code of the previous step rejected")
+	}
+	if VerifySecondFactor("admin", previous) {
+		t.Error("code accepted twice")
+	}
+
+	current := totpCode(key, uint64(step))
+	if !VerifySecondFactor("admin", current) {
+		t.Fatal("code of the current step rejected")
+	}
+	if VerifySecondFactor("admin", previous) {
+		t.Error("code older than the last used one accepted")
+	}
+	if VerifySecondFactor("admin", current) {
+		t.Error("current code accepted twice")
+	}
+	if VerifySecondFactor("other", current) {
+		t.Error("code accepted for a user without two-factor authentication")
+	}
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	enrollTOTP(t, "admin", "abcde-fghij", "klmno-pqrst")
+
+	if !VerifySecondFactor("admin", " ABCDE-FGHIJ ") {
+		t.Fatal("recovery code rejected")
+	}
+	if VerifySecondFactor("admin", "abcdefghij") {
+		t.Error("recovery code accepted twice")
+	}
+	if got := RecoveryCodesLeft("admin"); got != 1 {
+		t.Errorf("%d recovery codes left, want 1", got)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("jane doe", rfcSecret)
+	for _, want := range []string{"otpauth://totp/ZeroStat:jane%20doe?", "secret=" + rfcSecret, "issuer=ZeroStat", "period=30", "digits=6"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("%s does not contain %s", uri, want)
+		}
+	}
+}
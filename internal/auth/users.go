@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Roles, from least to most privileged
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Roles lists every assignable role, in display order.
+var Roles = []string{RoleViewer, RoleOperator, RoleAdmin}
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// RoleAllows reports whether a holder of role have may do what need requires.
+func RoleAllows(have, need string) bool {
+	return roleRank[have] > 0 && roleRank[have] >= roleRank[need]
+}
+
+// BootstrapAdmin is the built-in account authenticated by ZEROSTAT_PASSWORD. It always
+// exists so an instance can never be locked out by editing the user store.
+const BootstrapAdmin = "admin"
+
+const minPasswordLen = 8
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,32}$`)
+
+// User is a named dashboard account.
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+var (
+	usersMu sync.RWMutex
+	users   []User
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func usersPath() string {
+	return filepath.Join("data", "users.json")
+}
+
+// LoadUsers reads the user store from disk.
+func LoadUsers() {
+	fileBytes, err := os.ReadFile(usersPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: failed to read %s: %v", usersPath(), err)
+		}
+		return
+	}
+
+	var loaded []User
+	if err := json.Unmarshal(fileBytes, &loaded); err != nil {
+		log.Printf("Warning: failed to parse %s: %v", usersPath(), err)
+		return
+	}
+
+	usersMu.Lock()
+	users = loaded
+	usersMu.Unlock()
+	log.Printf("Loaded %d users from disk", len(loaded))
+}
+
+// saveUsers persists the store; callers must hold usersMu.
+func saveUsers() error {
+	if err := os.MkdirAll("data", 0755); err != nil {
+		return err
+	}
+	fileBytes, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(usersPath(), fileBytes, 0600)
+}
+
+// ListUsers returns a copy of all stored users.
+func ListUsers() []User {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	out := make([]User, len(users))
+	copy(out, users)
+	return out
+}
+
+// GetUser looks up a stored user by name.
+func GetUser(username string) (User, bool) {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	for _, u := range users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// CreateUser adds an account with a freshly hashed password.
+func CreateUser(username, password, role string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username must be 1-32 letters, digits, '.', '_' or '-'")
+	}
+	if username == BootstrapAdmin {
+		return fmt.Errorf("%q is reserved for the built-in administrator", BootstrapAdmin)
+	}
+	if len(password) < minPasswordLen {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	if roleRank[role] == 0 {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if _, exists := GetUser(username); exists {
+		return fmt.Errorf("user %q already exists", username)
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	users = append(users, User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    time.Now(),
+	})
+	if err := saveUsers(); err != nil {
+		users = users[:len(users)-1]
+		return err
+	}
+	return nil
+}
+
+// SetUserRole changes the role of a stored user.
+func SetUserRole(username, role string) error {
+	if roleRank[role] == 0 {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	for i := range users {
+		if users[i].Username == username {
+			users[i].Role = role
+			return saveUsers()
+		}
+	}
+	return fmt.Errorf("user %q not found", username)
+}
+
+// DeleteUser removes a stored user. Their sessions stop working on the next request.
+func DeleteUser(username string) bool {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	for i, u := range users {
+		if u.Username == username {
+			users = append(users[:i:i], users[i+1:]...)
+			if err := saveUsers(); err != nil {
+				log.Printf("Error writing users to disk: %v", err)
+			}
+			// A future account reusing the name must not inherit this second factor
+			DisableTOTP(username)
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate verifies the credentials of a stored user. Unknown names still pay for
+// a hash computation so response timing does not reveal which accounts exist.
+func Authenticate(username, password string) (User, bool) {
+	u, ok := GetUser(username)
+	if !ok {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = HashPassword("zerostat-dummy-password")
+		})
+		VerifyPassword(dummyHash, password)
+		return User{}, false
+	}
+	if !VerifyPassword(u.PasswordHash, password) {
+		return User{}, false
+	}
+	return u, true
+}
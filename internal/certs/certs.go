@@ -0,0 +1,231 @@
+// Package certs provides the TLS certificate for native HTTPS, either loaded from
+// configured files or self-signed and kept under data/tls, reloading it whenever
+// the files change on disk.
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// selfSignedValidity is how long a generated certificate lasts. It is renewed at
+// startup or by Watch once less than selfSignedRenewBefore remains.
+const (
+	selfSignedValidity    = 825 * 24 * time.Hour
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// Manager serves the current certificate to the TLS stack.
+type Manager struct {
+	certFile   string
+	keyFile    string
+	selfSigned bool
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// New loads the pair at certFile/keyFile. When both are empty a self-signed pair
+// in dir is used, generated on first run.
+func New(certFile, keyFile, dir string) (*Manager, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("both a certificate and a key file are required")
+	}
+
+	m := &Manager{certFile: certFile, keyFile: keyFile}
+	if certFile == "" {
+		m.certFile = filepath.Join(dir, "cert.pem")
+		m.keyFile = filepath.Join(dir, "key.pem")
+		m.selfSigned = true
+		if err := m.ensureSelfSigned(); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SelfSigned reports whether the manager generated its own certificate.
+func (m *Manager) SelfSigned() bool {
+	return m.selfSigned
+}
+
+// CertFile returns the path of the certificate being served.
+func (m *Manager) CertFile() string {
+	return m.certFile
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert, nil
+}
+
+// TLSConfig returns a server configuration serving the managed certificate.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+	}
+}
+
+// Watch polls the files every interval and swaps in the new pair after a change.
+// A broken pair is logged and the previous certificate kept.
+func (m *Manager) Watch(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if m.selfSigned {
+				if err := m.ensureSelfSigned(); err != nil {
+					log.Printf("Error renewing self-signed certificate: %v", err)
+				}
+			}
+			changed, err := m.changed()
+			if err != nil || !changed {
+				continue
+			}
+			if err := m.load(); err != nil {
+				log.Printf("Error reloading TLS certificate, keeping the previous one: %v", err)
+				continue
+			}
+			log.Printf("Reloaded TLS certificate from %s", m.certFile)
+		}
+	}()
+}
+
+// latestModTime is the newer modification time of the two files.
+func (m *Manager) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{m.certFile, m.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (m *Manager) changed() (bool, error) {
+	latest, err := m.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return !latest.Equal(m.modTime), nil
+}
+
+func (m *Manager) load() error {
+	modTime, err := m.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.cert = &cert
+	m.modTime = modTime
+	m.mu.Unlock()
+	return nil
+}
+
+// ensureSelfSigned generates the self-signed pair when it is missing or about to expire.
+func (m *Manager) ensureSelfSigned() error {
+	if pemBytes, err := os.ReadFile(m.certFile); err == nil {
+		if block, _ := pem.Decode(pemBytes); block != nil {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil &&
+				time.Until(cert.NotAfter) > selfSignedRenewBefore {
+				return nil
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	certPEM, keyPEM, err := generateSelfSigned()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.certFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.certFile, certPEM, 0644); err != nil {
+		return err
+	}
+	log.Printf("Generated self-signed TLS certificate in %s", m.certFile)
+	return nil
+}
+
+// generateSelfSigned creates an ECDSA P-256 certificate valid for localhost, the
+// host name and every local interface address.
+func generateSelfSigned() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"ZeroStat"}, CommonName: hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname != "" && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,82 +24,279 @@ type NotificationConfig struct {
 	SmtpTo     string
 }
 
+// RetentionConfig controls how long on-disk history is kept: each tier of the
+// metrics store, resolved incidents and the audit log. Zero keeps forever.
+type RetentionConfig struct {
+	Raw       time.Duration
+	Minute    time.Duration
+	Hour      time.Duration
+	Incidents time.Duration // resolved incidents, counted from their end
+	Audit     time.Duration
+}
+
+// LoginLimitConfig is the per-IP brute force policy for the login form
+type LoginLimitConfig struct {
+	MaxFailures int
+	Window      time.Duration
+	Lockout     time.Duration
+}
+
+// TLSConfig controls native HTTPS. Empty cert and key files mean a self-signed
+// certificate managed under data/tls.
+type TLSConfig struct {
+	Enabled      bool
+	CertFile     string
+	KeyFile      string
+	RedirectPort string // plain HTTP port redirecting to HTTPS, empty to disable
+}
+
+// DiskConfig selects the filesystems reported per mountpoint. Include and Exclude
+// are comma separated glob patterns matched against host mountpoints.
+type DiskConfig struct {
+	HostRoot string // where the host's / is mounted, empty to auto-detect /host/root
+	Include  string
+	Exclude  string
+}
+
 type Config struct {
-	mu         sync.RWMutex
-	Port       string
-	Password   string
-	Theme      string
-	Locale     string
-	AlertRules []AlertRule
-	Notif      NotificationConfig
+	mu             sync.RWMutex
+	Port           string
+	PasswordHash   string // ZEROSTAT_PASSWORD, plaintext only until migrated at startup
+	Theme          string
+	Locale         string
+	AlertRules     []AlertRule
+	Notif          NotificationConfig
+	Retention      RetentionConfig
+	SampleInterval time.Duration
+	MetricsToken   string
+	LoginLimit     LoginLimitConfig
+	TrustedProxy   string // header carrying the client IP behind a reverse proxy
+	TLS            TLSConfig
+	Disks          DiskConfig
+	NetInterfaces  string // comma separated glob allowlist of monitored interfaces
 }
 
+// AlertRule fires once its condition has held for DurationSeconds. The condition
+// is MetricType compared with Operator against ThresholdPercent in Unit, or an
+// Expression combining several such comparisons. Trend operators such as "avg>"
+// or "full<" compare a value derived from the last WindowMinutes of history
+// instead of the latest sample. A fired rule resolves once the value is back past
+// RecoveryThreshold (ThresholdPercent when nil) and has stayed there for
+// RecoverySeconds.
 type AlertRule struct {
 	ID                  string
-	MetricType          string  // CPU, RAM, Disk
-	Operator            string  // e.g., ">", "<", "="
-	ThresholdPercent    float64 // 90.0, etc
-	DurationSeconds     int     // 600 (10 minutes)
-	CooldownSeconds     int     // Wait time before triggering again
-	SentCount           int     // Number of times triggered
-	MessageTemplate     string  // e.g., "CPU usage is {{.Value}}%, exceeding {{.Threshold}}%"
-	ShellCommand        string  // e.g. docker stop $(docker ps -q)
-	NotificationChannel string  // webhook, telegram, etc
+	MetricType          string   // metrics.Catalog name, optionally with an instance as in "Disk:/var"
+	Expression          string   // e.g. "CPU > 90% && RAM > 80%", replaces the single metric fields
+	Operator            string   // ">", "<", "==" or a trend operator
+	ThresholdPercent    float64  // 90.0, etc, expressed in Unit
+	Unit                string   // %, KB/s, MB, h, etc; empty for rules saved before units existed
+	WindowMinutes       int      // history window of trend operators
+	DurationSeconds     int      // 600 (10 minutes)
+	CooldownSeconds     int      // Wait time before triggering again
+	RecoveryThreshold   *float64 // optional, in Unit
+	RecoverySeconds     int      // how long the value has to stay recovered
+	SentCount           int      // Number of times triggered
+	MessageTemplate     string   // e.g., "CPU usage is {{.Value}}%, exceeding {{.Threshold}}%"
+	ShellCommand        string   // e.g. docker stop $(docker ps -q)
+	NotificationChannel string   // webhook, telegram, etc
 	IsActive            bool
 	
 	// Internal State
-	ViolatingSince *time.Time
-	HasTriggered   bool
-	LastSentAt     *time.Time
+	ViolatingSince  *time.Time
+	HasTriggered    bool
+	LastSentAt      *time.Time
+	RecoveringSince *time.Time // while waiting out RecoverySeconds
+}
+
+// ThresholdUnit is the unit of ThresholdPercent. Rules without one predate network
+// alerts and always target a percentage.
+func (r AlertRule) ThresholdUnit() string {
+	if r.Unit == "" {
+		return "%"
+	}
+	return r.Unit
 }
 
 var (
-	appConfig *Config
-	once      sync.Once
+	appConfig    *Config
+	once         sync.Once
+	inheritedEnv = map[string]bool{}
 )
 
 // Init loads initial configuration from .env or defaults
 func Init() {
 	once.Do(func() {
+		// Remember what the real environment set, it keeps precedence over .env on Reload
+		for _, kv := range os.Environ() {
+			if k, _, ok := strings.Cut(kv, "="); ok {
+				inheritedEnv[k] = true
+			}
+		}
 		_ = godotenv.Load() // Ignore error if .env doesn't exist
 
-		port := os.Getenv("ZEROSTAT_PORT")
-		if port == "" {
-			port = "9124"
-		}
+		appConfig = fromEnv()
+		LoadRules(appConfig)
+	})
+}
 
-		password := os.Getenv("ZEROSTAT_PASSWORD")
-		if password == "" {
-			password = "admin" // Default for local testing
-		}
+// FromEnvironment reports whether key was set in the process environment rather
+// than in .env. Such values take precedence, so writing key to .env has no effect.
+func FromEnvironment(key string) bool {
+	return inheritedEnv[key]
+}
 
-		locale := os.Getenv("APP_LANGUAGE")
-		if locale == "" {
-			locale = "en"
-		}
+// fromEnv builds a configuration from the current environment.
+func fromEnv() *Config {
+	port := os.Getenv("ZEROSTAT_PORT")
+	if port == "" {
+		port = "9124"
+	}
 
-		notif := NotificationConfig{
-			TgBotToken: os.Getenv("TG_BOT_TOKEN"),
-			TgChatId:   os.Getenv("TG_CHAT_ID"),
-			WebhookUrl: os.Getenv("WEBHOOK_URL"),
-			SmtpHost:   os.Getenv("SMTP_HOST"),
-			SmtpPort:   os.Getenv("SMTP_PORT"),
-			SmtpUser:   os.Getenv("SMTP_USER"),
-			SmtpPass:   os.Getenv("SMTP_PASS"),
-			SmtpTo:     os.Getenv("SMTP_TO"),
-		}
+	password := os.Getenv("ZEROSTAT_PASSWORD")
+	if password == "" {
+		password = "admin" // Default for local testing
+	}
+
+	locale := os.Getenv("APP_LANGUAGE")
+	if locale == "" {
+		locale = "en"
+	}
+
+	notif := NotificationConfig{
+		TgBotToken: os.Getenv("TG_BOT_TOKEN"),
+		TgChatId:   os.Getenv("TG_CHAT_ID"),
+		WebhookUrl: os.Getenv("WEBHOOK_URL"),
+		SmtpHost:   os.Getenv("SMTP_HOST"),
+		SmtpPort:   os.Getenv("SMTP_PORT"),
+		SmtpUser:   os.Getenv("SMTP_USER"),
+		SmtpPass:   os.Getenv("SMTP_PASS"),
+		SmtpTo:     os.Getenv("SMTP_TO"),
+	}
+
+	retention := RetentionConfig{
+		Raw:       envDuration("RETENTION_RAW", 24*time.Hour),
+		Minute:    envDuration("RETENTION_1M", 7*24*time.Hour),
+		Hour:      envDuration("RETENTION_1H", 365*24*time.Hour),
+		Incidents: envDuration("RETENTION_INCIDENTS", 90*24*time.Hour),
+		Audit:     envDuration("RETENTION_AUDIT", 365*24*time.Hour),
+	}
 
-		appConfig = &Config{
-			Port:       port,
-			Password:   password,
-			Theme:      "dark", // default theme
-			Locale:     locale,   
-			AlertRules: make([]AlertRule, 0),
-			Notif:      notif,
+	sampleInterval := envDuration("SAMPLE_INTERVAL", 2*time.Second)
+	if sampleInterval < time.Second {
+		sampleInterval = time.Second
+	}
+
+	loginLimit := LoginLimitConfig{
+		MaxFailures: envInt("LOGIN_MAX_FAILURES", 5),
+		Window:      envDuration("LOGIN_WINDOW", 15*time.Minute),
+		Lockout:     envDuration("LOGIN_LOCKOUT", 15*time.Minute),
+	}
+
+	tlsConfig := TLSConfig{
+		Enabled:      envBool("TLS_ENABLED", false),
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		RedirectPort: os.Getenv("TLS_REDIRECT_PORT"),
+	}
+
+	disks := DiskConfig{
+		HostRoot: os.Getenv("HOST_ROOT"),
+		Include:  os.Getenv("DISK_INCLUDE"),
+		Exclude:  os.Getenv("DISK_EXCLUDE"),
+	}
+
+	return &Config{
+		Port:           port,
+		PasswordHash:   password,
+		Theme:          "dark", // default theme
+		Locale:         locale,   
+		AlertRules:     make([]AlertRule, 0),
+		Notif:          notif,
+		Retention:      retention,
+		SampleInterval: sampleInterval,
+		MetricsToken:   os.Getenv("METRICS_TOKEN"),
+		LoginLimit:     loginLimit,
+		TrustedProxy:   os.Getenv("TRUSTED_PROXY_HEADER"),
+		TLS:            tlsConfig,
+		Disks:          disks,
+		NetInterfaces:  os.Getenv("NET_INTERFACES"),
+	}
+}
+
+// Reload re-reads .env and the rules file into the running configuration. The
+// theme, which is not stored in .env, is kept.
+func Reload() error {
+	values, err := godotenv.Read()
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		if !inheritedEnv[k] {
+			os.Setenv(k, v)
 		}
+	}
 
-		LoadRules(appConfig)
-	})
+	fresh := fromEnv()
+	c := Get()
+	c.mu.Lock()
+	c.Port = fresh.Port
+	c.PasswordHash = fresh.PasswordHash
+	c.Locale = fresh.Locale
+	c.Notif = fresh.Notif
+	c.Retention = fresh.Retention
+	c.SampleInterval = fresh.SampleInterval
+	c.MetricsToken = fresh.MetricsToken
+	c.LoginLimit = fresh.LoginLimit
+	c.TrustedProxy = fresh.TrustedProxy
+	c.TLS = fresh.TLS
+	c.Disks = fresh.Disks
+	c.NetInterfaces = fresh.NetInterfaces
+	c.mu.Unlock()
+
+	reloadRules(c)
+	return nil
+}
+
+// envDuration parses a Go duration (e.g. "24h") from the environment, falling back on error
+func envDuration(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		log.Printf("Warning: invalid %s=%q, using %s", key, raw, fallback)
+		return fallback
+	}
+	return d
+}
+
+// envInt parses a positive integer from the environment, falling back on error
+func envInt(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		log.Printf("Warning: invalid %s=%q, using %d", key, raw, fallback)
+		return fallback
+	}
+	return n
+}
+
+// envBool parses a boolean (true/false, 1/0) from the environment, falling back on error
+func envBool(key string, fallback bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using %t", key, raw, fallback)
+		return fallback
+	}
+	return b
 }
 
 // Get access the singleton configuration
@@ -120,16 +319,17 @@ func (c *Config) SetPort(port string) {
 	c.Port = port
 }
 
-func (c *Config) GetPassword() string {
+func (c *Config) GetPasswordHash() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.Password
+	return c.PasswordHash
 }
 
-func (c *Config) SetPassword(pwd string) {
+// SetPasswordHash replaces the admin credential; callers hash the password first.
+func (c *Config) SetPasswordHash(hash string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.Password = pwd
+	c.PasswordHash = hash
 }
 
 func (c *Config) GetTheme() string {
@@ -171,6 +371,51 @@ func (c *Config) SetRules(rules []AlertRule) {
 	c.AlertRules = rules
 }
 
+// GetRule returns a copy of a single rule by ID
+func (c *Config) GetRule(id string) (AlertRule, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, r := range c.AlertRules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return AlertRule{}, false
+}
+
+// AddRule appends a rule under the write lock so concurrent engine updates are kept
+func (c *Config) AddRule(rule AlertRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AlertRules = append(c.AlertRules, rule)
+}
+
+// UpdateRule applies fn to the rule with the given ID in place
+func (c *Config) UpdateRule(id string, fn func(*AlertRule)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.AlertRules {
+		if c.AlertRules[i].ID == id {
+			fn(&c.AlertRules[i])
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteRule removes the rule with the given ID
+func (c *Config) DeleteRule(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, r := range c.AlertRules {
+		if r.ID == id {
+			c.AlertRules = append(c.AlertRules[:i:i], c.AlertRules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Config) UpdateRuleState(id string, violatingSince *time.Time, hasTriggered bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -178,6 +423,20 @@ func (c *Config) UpdateRuleState(id string, violatingSince *time.Time, hasTrigge
 		if r.ID == id {
 			c.AlertRules[i].ViolatingSince = violatingSince
 			c.AlertRules[i].HasTriggered = hasTriggered
+			c.AlertRules[i].RecoveringSince = nil
+			break
+		}
+	}
+}
+
+// SetRuleRecovering records since when a triggered rule has been back on the safe
+// side, nil when it left it again.
+func (c *Config) SetRuleRecovering(id string, since *time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, r := range c.AlertRules {
+		if r.ID == id {
+			c.AlertRules[i].RecoveringSince = since
 			break
 		}
 	}
@@ -208,22 +467,89 @@ func (c *Config) SetNotif(n NotificationConfig) {
 	c.Notif = n
 }
 
+func (c *Config) GetRetention() RetentionConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Retention
+}
+
+func (c *Config) GetLoginLimit() LoginLimitConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.LoginLimit
+}
+
+func (c *Config) GetTrustedProxy() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.TrustedProxy
+}
+
+func (c *Config) GetTLS() TLSConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.TLS
+}
+
+func (c *Config) GetDisks() DiskConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Disks
+}
+
+func (c *Config) GetNetInterfaces() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.NetInterfaces
+}
+
+func (c *Config) GetSampleInterval() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SampleInterval
+}
+
+func (c *Config) GetMetricsToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.MetricsToken
+}
+
 func (c *Config) SaveEnv() {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	envMap := map[string]string{
-		"ZEROSTAT_PORT":     c.Port,
-		"ZEROSTAT_PASSWORD": c.Password,
-		"APP_LANGUAGE":      c.Locale,
-		"TG_BOT_TOKEN":      c.Notif.TgBotToken,
-		"TG_CHAT_ID":        c.Notif.TgChatId,
-		"WEBHOOK_URL":       c.Notif.WebhookUrl,
-		"SMTP_HOST":         c.Notif.SmtpHost,
-		"SMTP_PORT":         c.Notif.SmtpPort,
-		"SMTP_USER":         c.Notif.SmtpUser,
-		"SMTP_PASS":         c.Notif.SmtpPass,
-		"SMTP_TO":           c.Notif.SmtpTo,
+		"ZEROSTAT_PORT":        c.Port,
+		"ZEROSTAT_PASSWORD":    c.PasswordHash,
+		"APP_LANGUAGE":         c.Locale,
+		"TG_BOT_TOKEN":         c.Notif.TgBotToken,
+		"TG_CHAT_ID":           c.Notif.TgChatId,
+		"WEBHOOK_URL":          c.Notif.WebhookUrl,
+		"SMTP_HOST":            c.Notif.SmtpHost,
+		"SMTP_PORT":            c.Notif.SmtpPort,
+		"SMTP_USER":            c.Notif.SmtpUser,
+		"SMTP_PASS":            c.Notif.SmtpPass,
+		"SMTP_TO":              c.Notif.SmtpTo,
+		"RETENTION_RAW":        c.Retention.Raw.String(),
+		"RETENTION_1M":         c.Retention.Minute.String(),
+		"RETENTION_1H":         c.Retention.Hour.String(),
+		"RETENTION_INCIDENTS":  c.Retention.Incidents.String(),
+		"RETENTION_AUDIT":      c.Retention.Audit.String(),
+		"SAMPLE_INTERVAL":      c.SampleInterval.String(),
+		"METRICS_TOKEN":        c.MetricsToken,
+		"LOGIN_MAX_FAILURES":   strconv.Itoa(c.LoginLimit.MaxFailures),
+		"LOGIN_WINDOW":         c.LoginLimit.Window.String(),
+		"LOGIN_LOCKOUT":        c.LoginLimit.Lockout.String(),
+		"TRUSTED_PROXY_HEADER": c.TrustedProxy,
+		"TLS_ENABLED":          strconv.FormatBool(c.TLS.Enabled),
+		"TLS_CERT_FILE":        c.TLS.CertFile,
+		"TLS_KEY_FILE":         c.TLS.KeyFile,
+		"TLS_REDIRECT_PORT":    c.TLS.RedirectPort,
+		"HOST_ROOT":            c.Disks.HostRoot,
+		"DISK_INCLUDE":         c.Disks.Include,
+		"DISK_EXCLUDE":         c.Disks.Exclude,
+		"NET_INTERFACES":       c.NetInterfaces,
 	}
 
 	godotenv.Write(envMap, ".env")
@@ -231,6 +557,44 @@ func (c *Config) SaveEnv() {
 }
 
 func LoadRules(c *Config) {
+	rules, ok := readRules()
+	if !ok {
+		return
+	}
+	c.SetRules(rules)
+	log.Printf("Loaded %d rules from disk", len(rules))
+}
+
+// reloadRules replaces the rules with the rules file while keeping the runtime
+// state of rules that are still there. The file only holds the state of the last
+// edit, so taking it as is would forget which rules are firing.
+func reloadRules(c *Config) {
+	rules, ok := readRules()
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	current := make(map[string]AlertRule, len(c.AlertRules))
+	for _, r := range c.AlertRules {
+		current[r.ID] = r
+	}
+	for i, r := range rules {
+		if old, ok := current[r.ID]; ok {
+			rules[i].ViolatingSince = old.ViolatingSince
+			rules[i].HasTriggered = old.HasTriggered
+			rules[i].LastSentAt = old.LastSentAt
+			rules[i].RecoveringSince = old.RecoveringSince
+			rules[i].SentCount = old.SentCount
+		}
+	}
+	c.AlertRules = rules
+	c.mu.Unlock()
+	log.Printf("Reloaded %d rules from disk", len(rules))
+}
+
+// readRules parses data/rules.json; ok is false when it is missing or invalid.
+func readRules() ([]AlertRule, bool) {
 	if err := os.MkdirAll("data", 0755); err != nil {
 		log.Printf("Warning: failed to create data directory: %v", err)
 	}
@@ -241,17 +605,15 @@ func LoadRules(c *Config) {
 		if !os.IsNotExist(err) {
 			log.Printf("Warning: failed to read %s: %v", filePath, err)
 		}
-		return
+		return nil, false
 	}
 
 	var rules []AlertRule
 	if err := json.Unmarshal(fileBytes, &rules); err != nil {
 		log.Printf("Warning: failed to parse %s: %v", filePath, err)
-		return
+		return nil, false
 	}
-
-	c.SetRules(rules)
-	log.Printf("Loaded %d rules from disk", len(rules))
+	return rules, true
 }
 
 func (c *Config) SaveRules() {
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadRulesKeepsTriggeredState(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	since := time.Now().Add(-time.Minute)
+	sent := time.Now().Add(-30 * time.Second)
+	c := &Config{AlertRules: []AlertRule{
+		{ID: "firing", MetricType: "CPU", Operator: ">", ThresholdPercent: 90, IsActive: true,
+			ViolatingSince: &since, HasTriggered: true, LastSentAt: &sent, SentCount: 3},
+		{ID: "deleted", MetricType: "RAM", Operator: ">", ThresholdPercent: 80},
+	}}
+
+	// The file holds the state of the last edit, not the running one
+	onDisk := []AlertRule{
+		{ID: "firing", MetricType: "CPU", Operator: ">", ThresholdPercent: 95, IsActive: true},
+		{ID: "added", MetricType: "Disk", Operator: ">", ThresholdPercent: 90},
+	}
+	data, err := json.Marshal(onDisk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("data", "rules.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloadRules(c)
+
+	rules := c.GetRules()
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	firing, ok := c.GetRule("firing")
+	if !ok {
+		t.Fatal("rule firing missing after reload")
+	}
+	if firing.ThresholdPercent != 95 {
+		t.Errorf("threshold = %v, want the reloaded 95", firing.ThresholdPercent)
+	}
+	if !firing.HasTriggered || firing.ViolatingSince == nil || !firing.ViolatingSince.Equal(since) {
+		t.Errorf("triggered state lost: HasTriggered=%v ViolatingSince=%v", firing.HasTriggered, firing.ViolatingSince)
+	}
+	if firing.SentCount != 3 || firing.LastSentAt == nil || !firing.LastSentAt.Equal(sent) {
+		t.Errorf("send state lost: SentCount=%d LastSentAt=%v", firing.SentCount, firing.LastSentAt)
+	}
+	if _, ok := c.GetRule("deleted"); ok {
+		t.Error("rule removed from the file is still loaded")
+	}
+	if added, ok := c.GetRule("added"); !ok || added.HasTriggered {
+		t.Errorf("new rule = %+v, %v; want it loaded without state", added, ok)
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/audit"
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/incidents"
+	"github.com/erysngl/zerostat/internal/metrics"
+	"github.com/erysngl/zerostat/internal/process"
+)
+
+// ruleResource is the JSON representation of config.AlertRule served by /api/v1.
+type ruleResource struct {
+	ID string `json:"id"`
+	ruleInput
+	SentCount       int        `json:"sent_count"`
+	ViolatingSince  *time.Time `json:"violating_since"`
+	Triggered       bool       `json:"triggered"`
+	LastSentAt      *time.Time `json:"last_sent_at"`
+	RecoveringSince *time.Time `json:"recovering_since,omitempty"`
+}
+
+func toRuleResource(rule config.AlertRule) ruleResource {
+	active := rule.IsActive
+	return ruleResource{
+		ID: rule.ID,
+		ruleInput: ruleInput{
+			MetricType:          rule.MetricType,
+			Expression:          rule.Expression,
+			Operator:            rule.Operator,
+			Threshold:           rule.ThresholdPercent,
+			Unit:                rule.ThresholdUnit(),
+			WindowMinutes:       rule.WindowMinutes,
+			DurationSeconds:     rule.DurationSeconds,
+			CooldownSeconds:     rule.CooldownSeconds,
+			RecoveryThreshold:   rule.RecoveryThreshold,
+			RecoverySeconds:     rule.RecoverySeconds,
+			MessageTemplate:     rule.MessageTemplate,
+			ShellCommand:        rule.ShellCommand,
+			NotificationChannel: rule.NotificationChannel,
+			IsActive:            &active,
+		},
+		SentCount:       rule.SentCount,
+		ViolatingSince:  rule.ViolatingSince,
+		Triggered:       rule.HasTriggered,
+		LastSentAt:      rule.LastSentAt,
+		RecoveringSince: rule.RecoveringSince,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// decodeRuleInput reads and validates a JSON rule body.
+func decodeRuleInput(w http.ResponseWriter, r *http.Request) (ruleInput, bool) {
+	var in ruleInput
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return in, false
+	}
+	if err := in.validate(); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return in, false
+	}
+	return in, true
+}
+
+// APIStats serves GET /api/v1/stats with the latest collector snapshot.
+func APIStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, metrics.Latest())
+}
+
+// APIHistory serves GET /api/v1/history. Without parameters it returns the
+// in-memory ring buffer; from/to/range/res/series query the persisted store.
+func APIHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("from") != "" || q.Get("to") != "" || q.Get("range") != "" || q.Get("res") != "" {
+		ServeHistory(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, metrics.History())
+}
+
+// APIProcesses serves GET /api/v1/processes with the same query, sort_by and
+// sort_dir parameters as the task manager.
+func APIProcesses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	sortBy := r.FormValue("sort_by")
+	if sortBy == "" {
+		sortBy = "cpu"
+	}
+	sortDir := r.FormValue("sort_dir")
+	if sortDir == "" {
+		sortDir = "desc"
+	}
+
+	procs := process.GetProcesses(r.FormValue("query"), sortBy, sortDir)
+	if procs == nil {
+		procs = []process.ProcessInfo{}
+	}
+	writeJSON(w, http.StatusOK, procs)
+}
+
+// APIKillProcess serves POST /api/v1/processes/{pid}/kill.
+func APIKillProcess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/processes/")
+	pidStr, action, _ := strings.Cut(rest, "/")
+	if action != "kill" {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+	pid, err := strconv.ParseInt(pidStr, 10, 32)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid PID")
+		return
+	}
+
+	err = process.KillProcess(int32(pid))
+	recordAudit(r, audit.ActionProcessKill, pidStr, err)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "kill failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pid": pid, "status": "signal sent"})
+}
+
+// APIStopContainer serves POST /api/v1/containers/{id}/stop.
+func APIStopContainer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/containers/")
+	id, action, _ := strings.Cut(rest, "/")
+	if action != "stop" || id == "" {
+		writeJSONError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	err := process.StopContainer(id)
+	recordAudit(r, audit.ActionContainerStop, id, err)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "stop failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "status": "stopped"})
+}
+
+// APIRules serves the /api/v1/rules collection (GET list, POST create).
+func APIRules(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+
+	switch r.Method {
+	case http.MethodGet:
+		rules := cfg.GetRules()
+		out := make([]ruleResource, 0, len(rules))
+		for _, rule := range rules {
+			out = append(out, toRuleResource(rule))
+		}
+		writeJSON(w, http.StatusOK, out)
+
+	case http.MethodPost:
+		in, ok := decodeRuleInput(w, r)
+		if !ok {
+			return
+		}
+		rule := newRule(in)
+		cfg.AddRule(rule)
+		cfg.SaveRules()
+		recordAudit(r, audit.ActionRuleCreate, rule.ID, nil)
+
+		w.Header().Set("Location", "/api/v1/rules/"+rule.ID)
+		writeJSON(w, http.StatusCreated, toRuleResource(rule))
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// APIRule serves a single /api/v1/rules/{id} (GET, PUT replace, DELETE).
+func APIRule(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/rules/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		writeJSONError(w, http.StatusNotFound, "rule not found")
+		return
+	}
+	cfg := config.Get()
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, ok := cfg.GetRule(id)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, toRuleResource(rule))
+
+	case http.MethodPut:
+		in, ok := decodeRuleInput(w, r)
+		if !ok {
+			return
+		}
+		if !cfg.UpdateRule(id, in.apply) {
+			writeJSONError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		// The condition may have changed, so restart the debounce tracking
+		cfg.UpdateRuleState(id, nil, false)
+		cfg.SaveRules()
+		incidents.Close(id, "rule updated")
+		recordAudit(r, audit.ActionRuleUpdate, id, nil)
+
+		rule, _ := cfg.GetRule(id)
+		writeJSON(w, http.StatusOK, toRuleResource(rule))
+
+	case http.MethodDelete:
+		if !cfg.DeleteRule(id) {
+			writeJSONError(w, http.StatusNotFound, "rule not found")
+			return
+		}
+		cfg.SaveRules()
+		incidents.Close(id, "rule deleted")
+		recordAudit(r, audit.ActionRuleDelete, id, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
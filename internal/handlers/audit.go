@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/audit"
+	"github.com/erysngl/zerostat/internal/auth"
+)
+
+const auditPageSize = 50
+
+// recordAudit logs a privileged action performed through r; a non-nil err marks it failed.
+func recordAudit(r *http.Request, action, target string, err error) {
+	e := audit.Entry{
+		User:    auth.Actor(r),
+		IP:      auth.ClientIP(r),
+		Action:  action,
+		Target:  target,
+		Outcome: audit.Success,
+	}
+	if err != nil {
+		e.Outcome = audit.Failure
+		e.Detail = err.Error()
+	}
+	audit.Record(e)
+}
+
+// auditFilter reads the filter form shared by the audit page and its export.
+// Dates come from <input type="date"> and cover whole days.
+func auditFilter(r *http.Request) audit.Filter {
+	f := audit.Filter{
+		User:    r.FormValue("user"),
+		Action:  r.FormValue("action"),
+		Outcome: r.FormValue("outcome"),
+		Text:    r.FormValue("q"),
+	}
+	if from, err := time.ParseInLocation("2006-01-02", r.FormValue("from"), time.Local); err == nil {
+		f.From = from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", r.FormValue("to"), time.Local); err == nil {
+		f.To = to.Add(24*time.Hour - time.Nanosecond)
+	}
+	return f
+}
+
+// auditQuery rebuilds the filter query string so pagination and export keep it.
+func auditQuery(r *http.Request, page int) string {
+	q := url.Values{}
+	for _, key := range []string{"user", "action", "outcome", "q", "from", "to"} {
+		if v := r.FormValue(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	return q.Encode()
+}
+
+// ServeAudit renders the filterable, paginated audit log.
+func ServeAudit(w http.ResponseWriter, r *http.Request) {
+	data := getBaseData(r)
+
+	page := 1
+	if p, err := strconv.Atoi(r.FormValue("page")); err == nil && p > 0 {
+		page = p
+	}
+
+	entries, total, err := audit.Query(auditFilter(r), (page-1)*auditPageSize, auditPageSize)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	totalPages := (total + auditPageSize - 1) / auditPageSize
+
+	data.Data = struct {
+		Entries    []audit.Entry
+		Total      int
+		Page       int
+		TotalPages int
+		HasPrev    bool
+		HasNext    bool
+		PrevURL    string
+		NextURL    string
+		ExportURL  string
+		Actions    []string
+		Outcomes   []string
+		User       string
+		Action     string
+		Outcome    string
+		Query      string
+		From       string
+		To         string
+	}{
+		Entries:    entries,
+		Total:      total,
+		Page:       page,
+		TotalPages: totalPages,
+		HasPrev:    page > 1,
+		HasNext:    page < totalPages,
+		PrevURL:    "/audit?" + auditQuery(r, page-1),
+		NextURL:    "/audit?" + auditQuery(r, page+1),
+		ExportURL:  "/audit/export?" + auditQuery(r, 1),
+		Actions:    audit.Actions,
+		Outcomes:   []string{audit.Success, audit.Failure, audit.Denied},
+		User:       r.FormValue("user"),
+		Action:     r.FormValue("action"),
+		Outcome:    r.FormValue("outcome"),
+		Query:      r.FormValue("q"),
+		From:       r.FormValue("from"),
+		To:         r.FormValue("to"),
+	}
+	tmplCache["audit.html"].ExecuteTemplate(w, "base.html", data)
+}
+
+// ExportAudit downloads every entry matching the current filter as JSON.
+func ExportAudit(w http.ResponseWriter, r *http.Request) {
+	entries, _, err := audit.Query(auditFilter(r), 0, 0)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	name := "zerostat-audit-" + time.Now().Format("20060102-150405") + ".json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -3,30 +3,40 @@ package handlers
 import (
 	"fmt"
 	"html/template"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
 
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/erysngl/zerostat/internal/alerting"
+	"github.com/erysngl/zerostat/internal/audit"
 	"github.com/erysngl/zerostat/internal/auth"
 	"github.com/erysngl/zerostat/internal/config"
 	"github.com/erysngl/zerostat/internal/i18n"
+	"github.com/erysngl/zerostat/internal/incidents"
 	"github.com/erysngl/zerostat/internal/metrics"
-	"sync"
+	"github.com/erysngl/zerostat/internal/qrcode"
 )
 
-var (
-	tmplCache      map[string]*template.Template
-	loginMu        sync.Mutex
-	failedAttempts int
-)
+var tmplCache map[string]*template.Template
+
+// rebinder moves the dashboard listener to another port, see SetRebinder.
+var rebinder func(port string) error
+
+// SetRebinder registers how ServeSettings applies a port change while running.
+func SetRebinder(fn func(port string) error) {
+	rebinder = fn
+}
 
 // InitTemplates parses templates per page to avoid block name collisions
 func InitTemplates() {
 	tmplCache = make(map[string]*template.Template)
-	pages := []string{"login.html", "dashboard.html", "settings.html", "stats.html", "automation.html", "tasks.html"}
+	pages := []string{"login.html", "dashboard.html", "settings.html", "stats.html", "automation.html", "tasks.html", "audit.html", "incidents.html", "login_2fa.html"}
 
 	base := filepath.Join("templates", "base.html")
 	stats := filepath.Join("templates", "stats.html")
@@ -49,20 +59,31 @@ func InitTemplates() {
 }
 
 type PageData struct {
-	Theme  string
-	Locale string
-	T      func(string) template.HTML
-	Error  string
-	Info   string
-	Data   interface{}
+	Theme      string
+	Locale     string
+	T          func(string) template.HTML
+	Error      string
+	Info       string
+	Data       interface{}
+	User       string
+	Role       string
+	CanOperate bool
+	IsAdmin    bool
+	CSRFToken  string
 }
 
-func getBaseData() PageData {
+func getBaseData(r *http.Request) PageData {
 	cfg := config.Get()
+	user, role, _ := auth.CurrentUser(r)
 	return PageData{
-		Theme:  cfg.GetTheme(),
-		Locale: cfg.GetLocale(),
-		T:      i18n.TFunc(cfg.GetLocale()),
+		Theme:      cfg.GetTheme(),
+		Locale:     cfg.GetLocale(),
+		T:          i18n.TFunc(cfg.GetLocale()),
+		User:       user,
+		Role:       role,
+		CanOperate: auth.RoleAllows(role, auth.RoleOperator),
+		IsAdmin:    auth.RoleAllows(role, auth.RoleAdmin),
+		CSRFToken:  auth.CSRFToken(r),
 	}
 }
 
@@ -73,27 +94,52 @@ func ServeLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data := getBaseData()
+	data := getBaseData(r)
 
 	if r.Method == http.MethodPost {
+		username := strings.TrimSpace(r.FormValue("username"))
+		if username == "" {
+			// Single-user installs only ever had a password field
+			username = auth.BootstrapAdmin
+		}
 		password := r.FormValue("password")
+		ip := auth.ClientIP(r)
+		entry := audit.Entry{User: username, IP: ip, Action: audit.ActionLogin, Outcome: audit.Success}
+
+		if wait, locked := auth.LoginLockedFor(ip); locked {
+			entry.Outcome = audit.Denied
+			entry.Detail = "locked out"
+			audit.Record(entry)
+
+			data.Error = fmt.Sprintf(string(data.T("TooManyAttempts")), wait.Round(time.Second))
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			tmplCache["login.html"].ExecuteTemplate(w, "base.html", data)
+			return
+		}
 
-		loginMu.Lock()
-		delay := time.Duration(failedAttempts) * 500 * time.Millisecond
-		if delay > 5*time.Second {
-			delay = 5 * time.Second
+		role, ok := "", false
+		if username == auth.BootstrapAdmin {
+			role, ok = auth.RoleAdmin, auth.VerifyPassword(config.Get().GetPasswordHash(), password)
+		} else if u, found := auth.Authenticate(username, password); found {
+			role, ok = u.Role, true
 		}
-		loginMu.Unlock()
-		
-		if delay > 0 {
-			time.Sleep(delay)
+
+		if ok && auth.TOTPEnabled(username) {
+			// Password accepted; the login is only recorded once the second factor passes
+			if err := auth.BeginSecondFactor(w, r, username, role); err != nil {
+				data.Error = "Internal Server Error: " + err.Error()
+				tmplCache["login.html"].ExecuteTemplate(w, "base.html", data)
+				return
+			}
+			http.Redirect(w, r, "/login/2fa", http.StatusFound)
+			return
 		}
 
-		if password == config.Get().GetPassword() {
-			loginMu.Lock()
-			failedAttempts = 0
-			loginMu.Unlock()
-			err := auth.Login(w, r)
+		if ok {
+			audit.Record(entry)
+			auth.RecordLoginSuccess(ip)
+			err := auth.Login(w, r, username, role)
 			if err != nil {
 				data.Error = "Internal Server Error: " + err.Error()
 				tmplCache["login.html"].ExecuteTemplate(w, "base.html", data)
@@ -102,11 +148,14 @@ func ServeLogin(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, "/", http.StatusFound)
 			return
 		}
-		
-		loginMu.Lock()
-		failedAttempts++
-		loginMu.Unlock()
-		
+
+		entry.Outcome = audit.Failure
+		if auth.RecordLoginFailure(ip) {
+			entry.Detail = "too many failures, client locked out"
+			log.Printf("[AUTH] Locked out %s after repeated failed logins", ip)
+		}
+		audit.Record(entry)
+
 		data.Error = string(data.T("InvalidCreds"))
 	}
 
@@ -121,46 +170,92 @@ func ServeLogout(w http.ResponseWriter, r *http.Request) {
 
 // ServeDashboard renders the main layout
 func ServeDashboard(w http.ResponseWriter, r *http.Request) {
-	data := getBaseData()
-	data.Data = metrics.GetFormatted()
+	data := getBaseData(r)
+	data.Data = metrics.GetFormatted(sparklineSpan(r), r.FormValue("iface"))
 	tmplCache["dashboard.html"].ExecuteTemplate(w, "base.html", data)
 }
 
 // ServeStats serves just the stats snippet for HTMX polling
 func ServeStats(w http.ResponseWriter, r *http.Request) {
-	data := getBaseData()
-	data.Data = metrics.GetFormatted()
+	data := getBaseData(r)
+	data.Data = metrics.GetFormatted(sparklineSpan(r), r.FormValue("iface"))
 	tmplCache["stats.html"].ExecuteTemplate(w, "stats.html", data)
 }
 
 // ServeSettings manages application configuration changes
 func ServeSettings(w http.ResponseWriter, r *http.Request) {
-	data := getBaseData()
+	data := getBaseData(r)
 	cfg := config.Get()
 
 	if r.Method == http.MethodPost {
+		if !data.IsAdmin {
+			http.Error(w, "Forbidden: requires the admin role", http.StatusForbidden)
+			return
+		}
 		port := r.FormValue("port")
 		theme := r.FormValue("theme")
 		locale := r.FormValue("locale")
 		password := r.FormValue("password")
 
-		if port != "" {
+		// Hash up front so a failure leaves the in-memory settings untouched
+		var passwordHash string
+		if password != "" {
+			hash, err := auth.HashPassword(password)
+			if err != nil {
+				data.Error = "Internal Server Error: " + err.Error()
+				renderSettings(w, data, settingsFlash{})
+				return
+			}
+			passwordHash = hash
+		}
+
+		// Move the listener first so a port that cannot be bound changes nothing
+		portChanged := port != "" && port != cfg.GetPort()
+		if portChanged {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				data.Error = fmt.Sprintf(string(data.T("InvalidPort")), port)
+				renderSettings(w, data, settingsFlash{})
+				return
+			}
+			if rebinder != nil {
+				if err := rebinder(port); err != nil {
+					recordAudit(r, audit.ActionSettingsUpdate, "port", err)
+					data.Error = err.Error()
+					renderSettings(w, data, settingsFlash{})
+					return
+				}
+			}
+		}
+
+		// Names of the changed settings for the audit log; values may be secrets
+		var changed []string
+
+		if portChanged {
+			changed = append(changed, "port")
 			cfg.SetPort(port)
 		}
 		if theme == "dark" || theme == "light" {
+			if theme != cfg.GetTheme() {
+				changed = append(changed, "theme")
+			}
 			cfg.SetTheme(theme)
 		}
 		if locale == "en" || locale == "tr" {
+			if locale != cfg.GetLocale() {
+				changed = append(changed, "locale")
+			}
 			cfg.SetLocale(locale)
 		}
-		if password != "" {
-			cfg.SetPassword(password)
+		if passwordHash != "" {
+			changed = append(changed, "password")
+			cfg.SetPasswordHash(passwordHash)
 		}
 
 		// Process Notification Settings if present in the form payload
 		r.ParseForm()
 		if _, ok := r.PostForm["tg_bot_token"]; ok {
-			notif := cfg.GetNotif()
+			previous := cfg.GetNotif()
+			notif := previous
 			notif.TgBotToken = r.FormValue("tg_bot_token")
 			notif.TgChatId = r.FormValue("tg_chat_id")
 			notif.WebhookUrl = r.FormValue("webhook_url")
@@ -169,54 +264,164 @@ func ServeSettings(w http.ResponseWriter, r *http.Request) {
 			notif.SmtpUser = r.FormValue("smtp_user")
 			notif.SmtpPass = r.FormValue("smtp_pass")
 			notif.SmtpTo = r.FormValue("smtp_to")
+			if notif != previous {
+				changed = append(changed, "notifications")
+			}
 			cfg.SetNotif(notif)
 		}
 
 		// Save everything to .env physically
 		cfg.SaveEnv()
+		if len(changed) > 0 {
+			recordAudit(r, audit.ActionSettingsUpdate, strings.Join(changed, ","), nil)
+		}
+
+		if portChanged && rebinder != nil {
+			// The old listener drains in the background, continue on the new address
+			http.Redirect(w, r, addressOnPort(r, port)+"/settings?moved=1", http.StatusSeeOther)
+			return
+		}
 
-		data = getBaseData() // Refresh references
+		data = getBaseData(r) // Refresh references
 		data.Info = string(data.T("SettingsSaved"))
+	} else if r.URL.Query().Get("moved") != "" {
+		data.Info = fmt.Sprintf(string(data.T("ListeningOnPort")), cfg.GetPort())
+	}
+
+	renderSettings(w, data, settingsFlash{})
+}
+
+// addressOnPort is the scheme and host r was sent to, with the port replaced.
+func addressOnPort(r *http.Request, port string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		host = strings.Trim(host, "[]") // bare IPv6 literal
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// settingsFlash carries secrets that are displayed exactly once on the settings page:
+// a freshly created API token or newly generated 2FA recovery codes.
+type settingsFlash struct {
+	NewToken      string
+	RecoveryCodes []string
+}
 
-		// Note: Restarting the HTTP server to bind a new port natively is complex without a manager.
-		// In a containerized context, a process restart is often preferred. 
-		// We could use a graceful restart package or signal here if needed.
-		// For now, settings are saved in memory and will persist until container restarts.
-		if port != "" && port != cfg.GetPort() {
-			go func() {
-				// We won't actually kill the process directly, just warning the user
-				// A real production system might listen for SIGHUP or similar.
-			}()
+// twoFactorView is the state of the signed-in user's second factor.
+type twoFactorView struct {
+	Enabled       bool
+	CodesLeft     int
+	PendingSecret string
+	PendingURI    template.URL  // html/template would reject the otpauth scheme
+	PendingQR     template.HTML // PendingURI as an inline SVG QR code
+	RecoveryCodes []string
+}
+
+// renderSettings prepares the current configuration to show in inputs. Everything
+// but the two-factor card is only filled in for admins.
+func renderSettings(w http.ResponseWriter, data PageData, flash settingsFlash) {
+	cfg := config.Get()
+
+	twoFactor := twoFactorView{
+		Enabled:       auth.TOTPEnabled(data.User),
+		CodesLeft:     auth.RecoveryCodesLeft(data.User),
+		RecoveryCodes: flash.RecoveryCodes,
+	}
+	if secret, ok := auth.PendingTOTPSecret(data.User); ok {
+		uri := auth.TOTPURI(data.User, secret)
+		twoFactor.PendingSecret = secret
+		twoFactor.PendingURI = template.URL(uri)
+		if code, err := qrcode.Encode(uri); err == nil {
+			twoFactor.PendingQR = template.HTML(code.SVG(200))
+		} else {
+			log.Printf("Warning: no QR code for the 2FA enrollment of %s: %v", data.User, err)
 		}
 	}
 
-	// Prepare current configuration to show in inputs
 	currentConfig := struct {
-		Port     string
-		Theme    string
-		Locale   string
-		Notif    config.NotificationConfig
+		Port        string
+		Theme       string
+		Locale      string
+		Notif       config.NotificationConfig
+		Tokens      []auth.APIToken
+		Scopes      []string
+		NewToken    string
+		Users       []auth.User
+		Roles       []string
+		Lockouts    []auth.Lockout
+		SessionKeys []auth.SessionKeyInfo
+		TOTPUsers   map[string]bool
+		TwoFactor   twoFactorView
 	}{
-		Port:     cfg.GetPort(),
-		Theme:    cfg.GetTheme(),
-		Locale:   cfg.GetLocale(),
-		Notif:    cfg.GetNotif(),
+		TwoFactor: twoFactor,
+	}
+	if data.IsAdmin {
+		currentConfig.Port = cfg.GetPort()
+		currentConfig.Theme = cfg.GetTheme()
+		currentConfig.Locale = cfg.GetLocale()
+		currentConfig.Notif = cfg.GetNotif()
+		currentConfig.Tokens = auth.ListTokens()
+		currentConfig.Scopes = auth.Scopes
+		currentConfig.NewToken = flash.NewToken
+		currentConfig.Users = auth.ListUsers()
+		currentConfig.Roles = auth.Roles
+		currentConfig.Lockouts = auth.Lockouts()
+		currentConfig.SessionKeys = auth.SessionKeys()
+		currentConfig.TOTPUsers = make(map[string]bool)
+		for _, u := range currentConfig.Users {
+			currentConfig.TOTPUsers[u.Username] = auth.TOTPEnabled(u.Username)
+		}
 	}
 	
 	data.Data = currentConfig
 	tmplCache["settings.html"].ExecuteTemplate(w, "base.html", data)
 }
 
+// automationRule is a rule as listed on the automation page, with expression
+// rules split into their conditions and connectives.
+type automationRule struct {
+	config.AlertRule
+	Terms []alerting.Term
+}
+
 // ServeAutomation renders the rules building interface
 func ServeAutomation(w http.ResponseWriter, r *http.Request) {
-	data := getBaseData()
+	data := getBaseData(r)
 	// Get rules from config state
-	data.Data = config.Get().GetRules()
+	rules := config.Get().GetRules()
+	views := make([]automationRule, len(rules))
+	for i, rule := range rules {
+		views[i] = automationRule{AlertRule: rule}
+		if rule.Expression == "" {
+			continue
+		}
+		if expr, err := alerting.ParseExpression(rule.Expression); err == nil {
+			views[i].Terms = expr.Terms()
+		} else {
+			views[i].Terms = []alerting.Term{{Text: rule.Expression}}
+		}
+	}
+	data.Data = struct {
+		Rules   []automationRule
+		Metrics []metrics.Target
+	}{
+		Rules:   views,
+		Metrics: metrics.Targets(),
+	}
 	
 	// Check for ?info= query params for banner
 	if info := r.URL.Query().Get("info"); info != "" {
 		data.Info = info
 	}
+	if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+		data.Error = errMsg
+	}
 
 	tmplCache["automation.html"].ExecuteTemplate(w, "base.html", data)
 }
@@ -228,29 +433,46 @@ func AddAutomationRule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cfg := config.Get()
-	rules := cfg.GetRules()
-
 	threshold, _ := strconv.ParseFloat(r.FormValue("threshold"), 64)
 	duration, _ := strconv.Atoi(r.FormValue("duration"))
 	cooldown, _ := strconv.Atoi(r.FormValue("cooldown"))
+	window, _ := strconv.Atoi(r.FormValue("window"))
+	recoverySecs, _ := strconv.Atoi(r.FormValue("recovery"))
+	var recovery *float64
+	if raw := strings.TrimSpace(r.FormValue("recovery_threshold")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			http.Redirect(w, r, "/automation?error="+url.QueryEscape("recovery threshold must be a number"), http.StatusFound)
+			return
+		}
+		recovery = &v
+	}
 
-	newRule := config.AlertRule{
-		ID:                  fmt.Sprintf("%d", time.Now().UnixNano()),
+	in := ruleInput{
 		MetricType:          r.FormValue("metric"),
+		Expression:          strings.TrimSpace(r.FormValue("expression")),
 		Operator:            r.FormValue("operator"),
-		ThresholdPercent:    threshold,
+		Threshold:           threshold,
+		Unit:                r.FormValue("unit"),
+		WindowMinutes:       window,
 		DurationSeconds:     duration,
 		CooldownSeconds:     cooldown,
-		SentCount:           0,
+		RecoveryThreshold:   recovery,
+		RecoverySeconds:     recoverySecs,
 		MessageTemplate:     r.FormValue("message_template"),
 		ShellCommand:        r.FormValue("command"),
 		NotificationChannel: r.FormValue("channel"),
-		IsActive:            true,
+	}
+	if err := in.validate(); err != nil {
+		http.Redirect(w, r, "/automation?error="+url.QueryEscape(err.Error()), http.StatusFound)
+		return
 	}
 
-	rules = append(rules, newRule)
-	cfg.SetRules(rules)
+	cfg := config.Get()
+	rule := newRule(in)
+	cfg.AddRule(rule)
+	cfg.SaveRules()
+	recordAudit(r, audit.ActionRuleCreate, rule.ID, nil)
 
 	http.Redirect(w, r, "/automation?info=Rule+Successfully+Added", http.StatusFound)
 }
@@ -270,10 +492,14 @@ func ToggleAutomationRule(w http.ResponseWriter, r *http.Request) {
 			// Reset tracking logic when toggling
 			rules[i].ViolatingSince = nil
 			rules[i].HasTriggered = false
+			rules[i].RecoveringSince = nil
 			break
 		}
 	}
 	cfg.SetRules(rules)
+	cfg.SaveRules()
+	incidents.Close(id, "rule toggled")
+	recordAudit(r, audit.ActionRuleToggle, id, nil)
 	http.Redirect(w, r, "/automation?info=Rule+Status+Updated", http.StatusFound)
 }
 
@@ -293,6 +519,9 @@ func DeleteAutomationRule(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	cfg.SetRules(newRules)
+	cfg.SaveRules()
+	incidents.Close(id, "rule deleted")
+	recordAudit(r, audit.ActionRuleDelete, id, nil)
 	http.Redirect(w, r, "/automation?info=Rule+Deleted", http.StatusFound)
 }
 
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erysngl/zerostat/internal/auth"
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/i18n"
+)
+
+func TestSettingsShowsPendingTOTP(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Symlink(filepath.Join(wd, "..", "..", "templates"), "templates"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Init()
+	InitTemplates()
+	if _, _, err := auth.BeginTOTPEnrollment("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	renderSettings(rec, PageData{T: i18n.TFunc("en"), User: "alice"}, settingsFlash{})
+	body := rec.Body.String()
+
+	if strings.Contains(body, "ZgotmplZ") {
+		t.Error("the otpauth link was replaced by html/template's unsafe URL marker")
+	}
+	if !strings.Contains(body, `href="otpauth://totp/ZeroStat:alice?`) {
+		t.Error("settings page has no otpauth link for the pending enrollment")
+	}
+	if !strings.Contains(body, "<svg") {
+		t.Error("settings page has no QR code for the pending enrollment")
+	}
+}
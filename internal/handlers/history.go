@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/metrics"
+	"github.com/erysngl/zerostat/internal/tsdb"
+)
+
+const (
+	// maxHistoryPoints caps a history response; longer results are downsampled.
+	maxHistoryPoints = 5000
+	// maxHistorySpan bounds queries when a tier is kept forever (retention 0).
+	maxHistorySpan = 10 * 365 * 24 * time.Hour
+)
+
+// historyHorizon is how far back the store keeps anything, the longest retention.
+func historyHorizon() time.Duration {
+	r := config.Get().GetRetention()
+	if r.Raw == 0 || r.Minute == 0 || r.Hour == 0 {
+		return maxHistorySpan
+	}
+	return max(r.Raw, r.Minute, r.Hour)
+}
+
+// parseSpan accepts Go durations plus a "d" suffix for days (e.g. "7d").
+func parseSpan(raw string) time.Duration {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "live" {
+		return 0
+	}
+	if strings.HasSuffix(raw, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		if err != nil || days <= 0 {
+			return 0
+		}
+		return time.Duration(days) * 24 * time.Hour
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// sparklineSpan is the dashboard's history range, at most the kept history.
+func sparklineSpan(r *http.Request) time.Duration {
+	return min(parseSpan(r.FormValue("range")), historyHorizon())
+}
+
+// parseTime accepts unix seconds or RFC3339 timestamps.
+func parseTime(raw string) (time.Time, bool) {
+	if raw == "" {
+		return time.Time{}, false
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// historyWindow resolves the from/to window of a history query, either from an
+// explicit from/to pair or from a trailing range (defaults to the last hour).
+func historyWindow(r *http.Request) (time.Time, time.Time) {
+	to := time.Now()
+	if t, ok := parseTime(r.FormValue("to")); ok {
+		to = t
+	}
+	if from, ok := parseTime(r.FormValue("from")); ok {
+		return from, to
+	}
+	span := parseSpan(r.FormValue("range"))
+	if span == 0 {
+		span = time.Hour
+	}
+	return to.Add(-span), to
+}
+
+// ServeHistory returns persisted metric history as JSON for arbitrary ranges.
+// Query params: from/to (unix or RFC3339) or range (e.g. 6h, 7d), res (raw, 1m, 1h)
+// and series (comma separated names, defaults to all). Ranges longer than the
+// longest retention are rejected and at most maxHistoryPoints points returned.
+func ServeHistory(w http.ResponseWriter, r *http.Request) {
+	from, to := historyWindow(r)
+	if !from.Before(to) {
+		http.Error(w, "Invalid time range", http.StatusBadRequest)
+		return
+	}
+	horizon := historyHorizon()
+	if to.Sub(from) > horizon {
+		http.Error(w, fmt.Sprintf("Time range is longer than the kept history (%s)", horizon), http.StatusBadRequest)
+		return
+	}
+	// Nothing older survives pruning, so the store need not look for it
+	if oldest := time.Now().Add(-horizon); from.Before(oldest) {
+		from = oldest
+	}
+	if !from.Before(to) {
+		http.Error(w, "Time range is older than the kept history", http.StatusBadRequest)
+		return
+	}
+
+	res := tsdb.Resolution(r.FormValue("res"))
+	points, err := metrics.QueryHistory(from, to, res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if res == "" {
+		res = tsdb.PickResolution(to.Sub(from))
+	}
+	if points == nil {
+		points = []tsdb.Point{}
+	}
+
+	if wanted := r.FormValue("series"); wanted != "" {
+		keep := strings.Split(wanted, ",")
+		for i := range points {
+			points[i] = filterPoint(points[i], keep)
+		}
+	}
+	points = tsdb.Downsample(points, maxHistoryPoints)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		From       time.Time       `json:"from"`
+		To         time.Time       `json:"to"`
+		Resolution tsdb.Resolution `json:"resolution"`
+		Series     []string        `json:"series"`
+		Points     []tsdb.Point    `json:"points"`
+	}{
+		From:       from,
+		To:         to,
+		Resolution: res,
+		Series:     tsdb.Names(points),
+		Points:     points,
+	})
+}
+
+func filterPoint(p tsdb.Point, keep []string) tsdb.Point {
+	pick := func(m map[string]float64) map[string]float64 {
+		if m == nil {
+			return nil
+		}
+		out := make(map[string]float64, len(keep))
+		for _, k := range keep {
+			if v, ok := m[strings.TrimSpace(k)]; ok {
+				out[strings.TrimSpace(k)] = v
+			}
+		}
+		return out
+	}
+	p.Avg = pick(p.Avg)
+	p.Min = pick(p.Min)
+	p.Max = pick(p.Max)
+	return p
+}
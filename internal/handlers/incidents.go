@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/erysngl/zerostat/internal/alerting"
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/incidents"
+)
+
+const incidentPageSize = 25
+
+// incidentRule is a rule option of the incidents page filter.
+type incidentRule struct {
+	ID    string
+	Label string
+}
+
+// incidentQuery rebuilds the filter query string so pagination keeps it.
+func incidentQuery(r *http.Request, page int) string {
+	q := url.Values{}
+	for _, key := range []string{"state", "rule"} {
+		if v := r.FormValue(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	return q.Encode()
+}
+
+// ServeIncidents renders alert incidents, open and resolved, optionally for a
+// single rule.
+func ServeIncidents(w http.ResponseWriter, r *http.Request) {
+	data := getBaseData(r)
+
+	page := 1
+	if p, err := strconv.Atoi(r.FormValue("page")); err == nil && p > 0 {
+		page = p
+	}
+
+	filter := incidents.Filter{RuleID: r.FormValue("rule"), State: r.FormValue("state")}
+	list, total, err := incidents.Query(filter, (page-1)*incidentPageSize, incidentPageSize)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	totalPages := (total + incidentPageSize - 1) / incidentPageSize
+
+	rules := config.Get().GetRules()
+	options := make([]incidentRule, len(rules))
+	for i, rule := range rules {
+		options[i] = incidentRule{ID: rule.ID, Label: alerting.RuleCondition(rule)}
+	}
+
+	data.Data = struct {
+		Incidents  []*incidents.Incident
+		Total      int
+		Page       int
+		TotalPages int
+		HasPrev    bool
+		HasNext    bool
+		PrevURL    string
+		NextURL    string
+		Rules      []incidentRule
+		States     []string
+		Rule       string
+		State      string
+	}{
+		Incidents:  list,
+		Total:      total,
+		Page:       page,
+		TotalPages: totalPages,
+		HasPrev:    page > 1,
+		HasNext:    page < totalPages,
+		PrevURL:    "/incidents?" + incidentQuery(r, page-1),
+		NextURL:    "/incidents?" + incidentQuery(r, page+1),
+		Rules:      options,
+		States:     []string{incidents.StateOpen, incidents.StateResolved},
+		Rule:       filter.RuleID,
+		State:      filter.State,
+	}
+	tmplCache["incidents.html"].ExecuteTemplate(w, "base.html", data)
+}
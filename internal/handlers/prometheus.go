@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/metrics"
+	"github.com/erysngl/zerostat/internal/process"
+)
+
+// promWriter emits the Prometheus text exposition format (version 0.0.4).
+type promWriter struct {
+	w    *bufio.Writer
+	seen map[string]bool
+}
+
+// family writes the HELP / TYPE header once per metric name.
+func (p *promWriter) family(name, kind, help string) {
+	if p.seen[name] {
+		return
+	}
+	p.seen[name] = true
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (p *promWriter) sample(name string, labels map[string]string, value float64) {
+	p.w.WriteString(name)
+	if len(labels) > 0 {
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k+`="`+labelEscaper.Replace(labels[k])+`"`)
+		}
+		p.w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	fmt.Fprintf(p.w, " %g\n", value)
+}
+
+func (p *promWriter) gauge(name, help string, value float64) {
+	p.family(name, "gauge", help)
+	p.sample(name, nil, value)
+}
+
+// labelEscaper applies the escaping rules of the exposition format to label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ServeMetrics exports system, alert rule and container metrics for Prometheus scraping.
+func ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	p := &promWriter{w: bufio.NewWriter(w), seen: make(map[string]bool)}
+	defer p.w.Flush()
+
+	s := metrics.Latest()
+	p.gauge("zerostat_cpu_usage_percent", "Aggregate CPU utilisation in percent.", s.CPUUsage)
+	p.gauge("zerostat_cpu_cores", "Number of logical CPU cores.", float64(s.CPUCores))
+	if len(s.CPUPerCore) > 0 {
+		p.family("zerostat_cpu_core_usage_percent", "gauge", "Utilisation of one logical core in percent.")
+		for i, pct := range s.CPUPerCore {
+			p.sample("zerostat_cpu_core_usage_percent", map[string]string{"core": strconv.Itoa(i)}, pct)
+		}
+	}
+	p.family("zerostat_cpu_time_percent", "gauge", "Share of CPU time spent per mode since the previous sample.")
+	for _, mode := range []struct {
+		name  string
+		value float64
+	}{{"user", s.CPUUser}, {"system", s.CPUSystem}, {"iowait", s.CPUIowait}, {"steal", s.CPUSteal}} {
+		p.sample("zerostat_cpu_time_percent", map[string]string{"mode": mode.name}, mode.value)
+	}
+	p.gauge("zerostat_load1", "1 minute load average.", s.Load1)
+	p.gauge("zerostat_load5", "5 minute load average.", s.Load5)
+	p.gauge("zerostat_load15", "15 minute load average.", s.Load15)
+	p.gauge("zerostat_context_switches_per_second", "Context switch rate since the previous sample.", s.CtxSwitches)
+	p.gauge("zerostat_memory_total_bytes", "Total physical memory in bytes.", float64(s.MemTotal))
+	p.gauge("zerostat_memory_used_bytes", "Used physical memory in bytes.", float64(s.MemUsed))
+	p.gauge("zerostat_memory_usage_percent", "Used physical memory in percent.", s.MemUsage)
+	p.gauge("zerostat_memory_available_bytes", "Memory available for new allocations without swapping, in bytes.", float64(s.MemAvailable))
+	p.gauge("zerostat_memory_cached_bytes", "Page cache in bytes.", float64(s.MemCached))
+	p.gauge("zerostat_memory_buffers_bytes", "Kernel buffers in bytes.", float64(s.MemBuffers))
+	p.gauge("zerostat_swap_total_bytes", "Total swap space in bytes.", float64(s.SwapTotal))
+	p.gauge("zerostat_swap_used_bytes", "Used swap space in bytes.", float64(s.SwapUsed))
+	p.gauge("zerostat_swap_usage_percent", "Used swap space in percent.", s.SwapUsage)
+	if len(s.Pressure) > 0 {
+		p.family("zerostat_pressure_stall_percent", "gauge", "Share of time tasks were stalled on the resource (Linux PSI).")
+		for _, resource := range []string{"cpu", "memory", "io"} {
+			ps, ok := s.Pressure[resource]
+			if !ok {
+				continue
+			}
+			for _, avg := range []struct {
+				kind, window string
+				value        float64
+			}{
+				{"some", "10s", ps.Some10}, {"some", "60s", ps.Some60}, {"some", "300s", ps.Some300},
+				{"full", "10s", ps.Full10}, {"full", "60s", ps.Full60}, {"full", "300s", ps.Full300},
+			} {
+				p.sample("zerostat_pressure_stall_percent", map[string]string{
+					"resource": resource,
+					"kind":     avg.kind,
+					"window":   avg.window,
+				}, avg.value)
+			}
+		}
+	}
+	p.gauge("zerostat_disk_total_bytes", "Total capacity of the root filesystem in bytes.", float64(s.DiskTotal))
+	p.gauge("zerostat_disk_used_bytes", "Used capacity of the root filesystem in bytes.", float64(s.DiskUsed))
+	p.gauge("zerostat_disk_usage_percent", "Used capacity of the root filesystem in percent.", s.DiskUsage)
+	// Sums over the interfaces present right now, which drop when one goes away,
+	// so they are no counters. Use zerostat_interface_*_total with rate().
+	p.gauge("zerostat_network_receive_bytes", "Bytes received so far, summed over the monitored interfaces.", float64(s.NetRx))
+	p.gauge("zerostat_network_transmit_bytes", "Bytes sent so far, summed over the monitored interfaces.", float64(s.NetTx))
+	p.gauge("zerostat_network_receive_bytes_per_second", "Current receive rate in bytes per second.", s.NetRxSpeed*1024)
+	p.gauge("zerostat_network_transmit_bytes_per_second", "Current transmit rate in bytes per second.", s.NetTxSpeed*1024)
+	p.gauge("zerostat_last_sample_timestamp_seconds", "Unix time of the latest collector snapshot.", float64(s.Timestamp.Unix()))
+
+	if len(s.Temperatures) > 0 {
+		p.family("zerostat_temperature_celsius", "gauge", "Current sensor temperature in degrees Celsius.")
+		for _, sensor := range s.Temperatures {
+			p.sample("zerostat_temperature_celsius", map[string]string{"sensor": sensor.Name}, sensor.Celsius)
+		}
+		p.family("zerostat_temperature_critical_celsius", "gauge", "Critical (or else high) limit reported for the sensor.")
+		for _, sensor := range s.Temperatures {
+			if limit := sensor.Limit(); limit > 0 {
+				p.sample("zerostat_temperature_critical_celsius", map[string]string{"sensor": sensor.Name}, limit)
+			}
+		}
+	}
+
+	deviceFamily := func(name, help string, value func(metrics.DiskDevice) float64) {
+		if len(s.DiskIO) == 0 {
+			return
+		}
+		p.family(name, "gauge", help)
+		for _, d := range s.DiskIO {
+			p.sample(name, map[string]string{"device": d.Name}, value(d))
+		}
+	}
+	deviceFamily("zerostat_disk_read_bytes_per_second", "Current read rate of the block device in bytes per second.",
+		func(d metrics.DiskDevice) float64 { return d.ReadSpeed * 1024 })
+	deviceFamily("zerostat_disk_write_bytes_per_second", "Current write rate of the block device in bytes per second.",
+		func(d metrics.DiskDevice) float64 { return d.WriteSpeed * 1024 })
+	deviceFamily("zerostat_disk_reads_per_second", "Completed read requests per second.",
+		func(d metrics.DiskDevice) float64 { return d.ReadIOPS })
+	deviceFamily("zerostat_disk_writes_per_second", "Completed write requests per second.",
+		func(d metrics.DiskDevice) float64 { return d.WriteIOPS })
+	deviceFamily("zerostat_disk_await_milliseconds", "Average time per completed request in milliseconds.",
+		func(d metrics.DiskDevice) float64 { return d.Await })
+	deviceFamily("zerostat_disk_busy_percent", "Share of time the block device had requests in flight.",
+		func(d metrics.DiskDevice) float64 { return d.Util })
+
+	ifaceFamily := func(name, kind, help string, value func(metrics.NetInterface) float64) {
+		if len(s.Interfaces) == 0 {
+			return
+		}
+		p.family(name, kind, help)
+		for _, iface := range s.Interfaces {
+			p.sample(name, map[string]string{"interface": iface.Name}, value(iface))
+		}
+	}
+	ifaceFamily("zerostat_interface_receive_bytes_total", "counter", "Bytes received on the interface.",
+		func(n metrics.NetInterface) float64 { return float64(n.RxBytes) })
+	ifaceFamily("zerostat_interface_transmit_bytes_total", "counter", "Bytes sent on the interface.",
+		func(n metrics.NetInterface) float64 { return float64(n.TxBytes) })
+	ifaceFamily("zerostat_interface_receive_packets_per_second", "gauge", "Current receive packet rate of the interface.",
+		func(n metrics.NetInterface) float64 { return n.RxPackets })
+	ifaceFamily("zerostat_interface_transmit_packets_per_second", "gauge", "Current transmit packet rate of the interface.",
+		func(n metrics.NetInterface) float64 { return n.TxPackets })
+	ifaceFamily("zerostat_interface_receive_errors_total", "counter", "Receive errors on the interface.",
+		func(n metrics.NetInterface) float64 { return float64(n.RxErrors) })
+	ifaceFamily("zerostat_interface_transmit_errors_total", "counter", "Transmit errors on the interface.",
+		func(n metrics.NetInterface) float64 { return float64(n.TxErrors) })
+	ifaceFamily("zerostat_interface_receive_drops_total", "counter", "Received packets dropped on the interface.",
+		func(n metrics.NetInterface) float64 { return float64(n.RxDrops) })
+	ifaceFamily("zerostat_interface_transmit_drops_total", "counter", "Outgoing packets dropped on the interface.",
+		func(n metrics.NetInterface) float64 { return float64(n.TxDrops) })
+
+	mountFamily := func(name, help string, value func(metrics.DiskMount) float64) {
+		if len(s.Disks) == 0 {
+			return
+		}
+		p.family(name, "gauge", help)
+		for _, d := range s.Disks {
+			p.sample(name, map[string]string{
+				"mountpoint": d.Mountpoint,
+				"device":     d.Device,
+				"fstype":     d.FSType,
+			}, value(d))
+		}
+	}
+	mountFamily("zerostat_filesystem_size_bytes", "Total capacity of the filesystem in bytes.",
+		func(d metrics.DiskMount) float64 { return float64(d.Total) })
+	mountFamily("zerostat_filesystem_used_bytes", "Used capacity of the filesystem in bytes.",
+		func(d metrics.DiskMount) float64 { return float64(d.Used) })
+	mountFamily("zerostat_filesystem_usage_percent", "Used capacity of the filesystem in percent.",
+		func(d metrics.DiskMount) float64 { return d.Usage })
+	mountFamily("zerostat_filesystem_inodes_total", "Total inodes of the filesystem.",
+		func(d metrics.DiskMount) float64 { return float64(d.InodesTotal) })
+	mountFamily("zerostat_filesystem_inodes_used", "Used inodes of the filesystem.",
+		func(d metrics.DiskMount) float64 { return float64(d.InodesUsed) })
+
+	// Samples of one family must be contiguous, so iterate the rules once per family
+	rules := config.Get().GetRules()
+	ruleFamily := func(name, kind, help string, value func(config.AlertRule) float64) {
+		if len(rules) == 0 {
+			return
+		}
+		p.family(name, kind, help)
+		for _, rule := range rules {
+			labels := map[string]string{
+				"rule_id":  rule.ID,
+				"metric":   rule.MetricType,
+				"operator": rule.Operator,
+				"unit":     rule.ThresholdUnit(),
+			}
+			if rule.Expression != "" {
+				// Compound rules have no single metric, expose the whole condition instead
+				labels["metric"], labels["operator"], labels["unit"] = rule.Expression, "", ""
+			}
+			p.sample(name, labels, value(rule))
+		}
+	}
+	ruleFamily("zerostat_alert_rule_active", "gauge", "Whether the rule is enabled (1) or disabled (0).",
+		func(rule config.AlertRule) float64 { return boolValue(rule.IsActive) })
+	ruleFamily("zerostat_alert_rule_threshold", "gauge", "Configured threshold of the rule.",
+		func(rule config.AlertRule) float64 { return rule.ThresholdPercent })
+	ruleFamily("zerostat_alert_rule_violating", "gauge", "Whether the rule condition currently holds.",
+		func(rule config.AlertRule) float64 { return boolValue(rule.ViolatingSince != nil) })
+	ruleFamily("zerostat_alert_rule_triggered", "gauge", "Whether the rule has fired and not yet recovered.",
+		func(rule config.AlertRule) float64 { return boolValue(rule.HasTriggered) })
+	ruleFamily("zerostat_alert_rule_recovering", "gauge", "Whether the fired rule is waiting out its recovery duration.",
+		func(rule config.AlertRule) float64 { return boolValue(rule.RecoveringSince != nil) })
+	ruleFamily("zerostat_alert_rule_sent_total", "counter", "Number of times the rule has fired.",
+		func(rule config.AlertRule) float64 { return float64(rule.SentCount) })
+
+	if r.FormValue("containers") == "0" {
+		return
+	}
+	containers := process.GetContainerStats()
+	containerFamily := func(name, help string, value func(process.ContainerStats) float64) {
+		if len(containers) == 0 {
+			return
+		}
+		p.family(name, "gauge", help)
+		for _, c := range containers {
+			p.sample(name, map[string]string{"id": c.ID, "name": c.Name}, value(c))
+		}
+	}
+	containerFamily("zerostat_container_cpu_percent", "Summed CPU usage of the container's processes.",
+		func(c process.ContainerStats) float64 { return c.CPU })
+	containerFamily("zerostat_container_memory_percent", "Summed memory usage of the container's processes in percent.",
+		func(c process.ContainerStats) float64 { return c.RAM })
+	containerFamily("zerostat_container_processes", "Number of processes running inside the container.",
+		func(c process.ContainerStats) float64 { return float64(c.Processes) })
+}
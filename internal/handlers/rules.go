@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/alerting"
+	"github.com/erysngl/zerostat/internal/config"
+	"github.com/erysngl/zerostat/internal/metrics"
+)
+
+var (
+	validOperators = append([]string{">", "<", "=="}, alerting.TrendOperators...)
+	validChannels  = []string{"", "none", "webhook", "telegram", "email"}
+)
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleInput carries the user editable fields of an alert rule, shared by the
+// automation form and the JSON API.
+type ruleInput struct {
+	MetricType          string   `json:"metric"`
+	Expression          string   `json:"expression,omitempty"`
+	Operator            string   `json:"operator"`
+	Threshold           float64  `json:"threshold"`
+	Unit                string   `json:"unit"`
+	WindowMinutes       int      `json:"window_minutes,omitempty"`
+	DurationSeconds     int      `json:"duration_seconds"`
+	CooldownSeconds     int      `json:"cooldown_seconds"`
+	RecoveryThreshold   *float64 `json:"recovery_threshold,omitempty"`
+	RecoverySeconds     int      `json:"recovery_seconds,omitempty"`
+	MessageTemplate     string   `json:"message_template"`
+	ShellCommand        string   `json:"command"`
+	NotificationChannel string   `json:"channel"`
+	IsActive            *bool    `json:"active"`
+}
+
+// validate rejects rules the alerting engine cannot evaluate. An empty unit is
+// replaced by the metric's default unit.
+func (in *ruleInput) validate() error {
+	if in.Expression != "" {
+		if err := in.validateExpression(); err != nil {
+			return err
+		}
+	} else {
+		if !contains(validOperators, in.Operator) {
+			return fmt.Errorf("unknown operator %q", in.Operator)
+		}
+		if alerting.IsTrend(in.Operator) {
+			if err := in.validateTrend(); err != nil {
+				return err
+			}
+		} else {
+			if err := validateCondition(in.MetricType, &in.Unit); err != nil {
+				return err
+			}
+			in.WindowMinutes = 0
+		}
+		if math.IsNaN(in.Threshold) || math.IsInf(in.Threshold, 0) {
+			return fmt.Errorf("threshold must be a finite number")
+		}
+	}
+	if in.DurationSeconds < 0 || in.DurationSeconds > 86400 {
+		return fmt.Errorf("duration_seconds must be between 0 and 86400")
+	}
+	if in.CooldownSeconds < 0 || in.CooldownSeconds > 86400 {
+		return fmt.Errorf("cooldown_seconds must be between 0 and 86400")
+	}
+	if in.RecoverySeconds < 0 || in.RecoverySeconds > 86400 {
+		return fmt.Errorf("recovery_seconds must be between 0 and 86400")
+	}
+	if in.RecoveryThreshold != nil {
+		if err := in.validateRecovery(); err != nil {
+			return err
+		}
+	}
+	if !contains(validChannels, in.NotificationChannel) {
+		return fmt.Errorf("unknown channel %q", in.NotificationChannel)
+	}
+	return nil
+}
+
+// validateCondition checks that metricType names an available metric and that
+// unit applies to it, defaulting an empty unit.
+func validateCondition(metricType string, unit *string) error {
+	metric, ok := metrics.LookupMetric(metricType)
+	if !ok {
+		return fmt.Errorf("unknown metric %q", metricType)
+	}
+	if _, instance := metrics.SplitInstance(metricType); instance != "" {
+		if !contains(metric.Instances(metrics.Latest()), instance) {
+			return fmt.Errorf("%s has no instance %q", metric.Name, instance)
+		}
+	}
+	if _, ok := metrics.Latest().Value(metricType, ""); !ok {
+		return fmt.Errorf("%s is not available on this host", metricType)
+	}
+	if *unit == "" {
+		*unit = metric.Units[0]
+	}
+	if !metric.AcceptsUnit(*unit) {
+		return fmt.Errorf("unit %q does not apply to %s, use one of %s", *unit, metricType, strings.Join(metric.Units, ", "))
+	}
+	return nil
+}
+
+// validateRecovery checks that the recovery threshold lies on the safe side of
+// the threshold, so the rule can only resolve after the value moved past it.
+func (in *ruleInput) validateRecovery() error {
+	recovery := *in.RecoveryThreshold
+	switch {
+	case in.Expression != "":
+		return fmt.Errorf("recovery_threshold does not apply to expression rules, use recovery_seconds")
+	case in.Operator == "==":
+		return fmt.Errorf("recovery_threshold does not apply to ==")
+	case math.IsNaN(recovery) || math.IsInf(recovery, 0):
+		return fmt.Errorf("recovery_threshold must be a finite number")
+	case alerting.FiresBelow(in.Operator) && recovery < in.Threshold:
+		return fmt.Errorf("recovery_threshold must not be below the threshold for %s", in.Operator)
+	case !alerting.FiresBelow(in.Operator) && recovery > in.Threshold:
+		return fmt.Errorf("recovery_threshold must not be above the threshold for %s", in.Operator)
+	}
+	return nil
+}
+
+// validateTrend checks a rule whose operator is evaluated over its history
+// window. Only metrics kept in the history store qualify, and "full<" needs a
+// percentage that can reach 100 and takes its threshold in hours.
+func (in *ruleInput) validateTrend() error {
+	unit := in.Unit
+	if in.Operator == alerting.OpFullIn {
+		unit = ""
+	}
+	if err := validateCondition(in.MetricType, &unit); err != nil {
+		return err
+	}
+	if _, ok := metrics.Latest().Series()[in.MetricType]; !ok {
+		return fmt.Errorf("%s has no history for trend operators", in.MetricType)
+	}
+	if in.Operator == alerting.OpFullIn {
+		if unit != metrics.UnitPercent {
+			return fmt.Errorf("%s only applies to percentages such as Disk or RAM", alerting.OpFullIn)
+		}
+		unit = alerting.UnitHours
+	}
+	in.Unit = unit
+	if in.WindowMinutes < 1 || in.WindowMinutes > 10080 {
+		return fmt.Errorf("window_minutes must be between 1 and 10080 for %s", in.Operator)
+	}
+	return nil
+}
+
+// validateExpression checks every condition of a compound rule and stores the
+// expression in canonical form, with explicit units. The single metric fields
+// are cleared since the engine ignores them.
+func (in *ruleInput) validateExpression() error {
+	expr, err := alerting.ParseExpression(in.Expression)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %v", err)
+	}
+	for _, cond := range expr.Conditions() {
+		if err := validateCondition(cond.Metric, &cond.Unit); err != nil {
+			return err
+		}
+	}
+	in.Expression = expr.String()
+	in.MetricType, in.Operator, in.Threshold, in.Unit, in.WindowMinutes = "", "", 0, "", 0
+	return nil
+}
+
+// apply copies the editable fields onto rule, leaving its runtime state untouched.
+func (in ruleInput) apply(rule *config.AlertRule) {
+	rule.MetricType = in.MetricType
+	rule.Expression = in.Expression
+	rule.Operator = in.Operator
+	rule.ThresholdPercent = in.Threshold
+	rule.Unit = in.Unit
+	rule.WindowMinutes = in.WindowMinutes
+	rule.DurationSeconds = in.DurationSeconds
+	rule.CooldownSeconds = in.CooldownSeconds
+	rule.RecoveryThreshold = in.RecoveryThreshold
+	rule.RecoverySeconds = in.RecoverySeconds
+	rule.MessageTemplate = in.MessageTemplate
+	rule.ShellCommand = in.ShellCommand
+	rule.NotificationChannel = in.NotificationChannel
+	if in.IsActive != nil {
+		rule.IsActive = *in.IsActive
+	}
+}
+
+// newRule builds a fresh, active rule from validated input.
+func newRule(in ruleInput) config.AlertRule {
+	rule := config.AlertRule{
+		ID:       fmt.Sprintf("%d", time.Now().UnixNano()),
+		IsActive: true,
+	}
+	in.apply(&rule)
+	return rule
+}
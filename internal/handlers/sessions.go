@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erysngl/zerostat/internal/audit"
+	"github.com/erysngl/zerostat/internal/auth"
+)
+
+// RotateSessionKeys starts signing new sessions with a fresh key. Existing sessions
+// keep working until they expire.
+func RotateSessionKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	err := auth.RotateSessionKeys()
+	recordAudit(r, audit.ActionSessionRotate, "", err)
+	if err != nil {
+		data.Error = err.Error()
+	} else {
+		data.Info = string(data.T("SessionKeysRotated"))
+	}
+	renderSettings(w, data, settingsFlash{})
+}
+
+// RevokeAllSessions signs every user out, the caller included.
+func RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	err := auth.RevokeAllSessions()
+	recordAudit(r, audit.ActionSessionRevoke, "", err)
+	if err != nil {
+		data := getBaseData(r)
+		data.Error = err.Error()
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
@@ -5,11 +5,12 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/erysngl/zerostat/internal/audit"
 	"github.com/erysngl/zerostat/internal/process"
 )
 
 func ServeTasks(w http.ResponseWriter, r *http.Request) {
-	data := getBaseData()
+	data := getBaseData(r)
 	// base.html requires .Data to not be nil to render the navbar
 	data.Data = struct{}{}
 	tmplCache["tasks.html"].ExecuteTemplate(w, "base.html", data)
@@ -73,7 +74,7 @@ func ServeTasksList(w http.ResponseWriter, r *http.Request) {
 		pages = append(pages, i)
 	}
 
-	data := getBaseData()
+	data := getBaseData(r)
 	data.Data = struct {
 		Processes  []process.ProcessInfo
 		Query      string
@@ -116,7 +117,9 @@ func HandleKillProcess(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := process.KillProcess(int32(pid)); err != nil {
+	err = process.KillProcess(int32(pid))
+	recordAudit(r, audit.ActionProcessKill, pidStr, err)
+	if err != nil {
 		fmt.Fprintf(w, "<div class='text-red-500 text-sm'>Kill Failed: %v</div>", err)
 		return
 	}
@@ -139,7 +142,9 @@ func HandleStopContainer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := process.StopContainer(id); err != nil {
+	err := process.StopContainer(id)
+	recordAudit(r, audit.ActionContainerStop, id, err)
+	if err != nil {
 		fmt.Fprintf(w, "<div class='text-red-500 text-sm'>Stop Failed: %v</div>", err)
 		return
 	}
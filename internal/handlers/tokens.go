@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/erysngl/zerostat/internal/audit"
+	"github.com/erysngl/zerostat/internal/auth"
+)
+
+// CreateAPIToken issues a new named token from the settings page and shows its
+// secret once.
+func CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+	r.ParseForm()
+
+	data := getBaseData(r)
+	secret, _, err := auth.CreateToken(r.FormValue("token_name"), r.PostForm["token_scopes"])
+	recordAudit(r, audit.ActionTokenCreate, r.FormValue("token_name"), err)
+	if err != nil {
+		data.Error = err.Error()
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+
+	data.Info = string(data.T("TokenCreated"))
+	renderSettings(w, data, settingsFlash{NewToken: secret})
+}
+
+// RevokeAPIToken deletes a token so it is rejected from now on.
+func RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	if auth.RevokeToken(r.FormValue("id")) {
+		recordAudit(r, audit.ActionTokenRevoke, r.FormValue("id"), nil)
+		data.Info = string(data.T("TokenRevoked"))
+	}
+	renderSettings(w, data, settingsFlash{})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/audit"
+	"github.com/erysngl/zerostat/internal/auth"
+)
+
+// ServeLoginSecondFactor is the second login step for users with TOTP enabled. It
+// accepts a 6 digit code or one of the user's recovery codes.
+func ServeLoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	if auth.Check(w, r) {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	username, role, pending := auth.PendingSecondFactor(r)
+	if !pending {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+
+	if r.Method == http.MethodPost {
+		ip := auth.ClientIP(r)
+		entry := audit.Entry{User: username, IP: ip, Action: audit.ActionLogin, Outcome: audit.Success, Detail: "second factor"}
+
+		if wait, locked := auth.LoginLockedFor(ip); locked {
+			entry.Outcome = audit.Denied
+			entry.Detail = "second factor: locked out"
+			audit.Record(entry)
+
+			data.Error = fmt.Sprintf(string(data.T("TooManyAttempts")), wait.Round(time.Second))
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			tmplCache["login_2fa.html"].ExecuteTemplate(w, "base.html", data)
+			return
+		}
+
+		if auth.VerifySecondFactor(username, r.FormValue("code")) {
+			audit.Record(entry)
+			auth.RecordLoginSuccess(ip)
+			if err := auth.Login(w, r, username, role); err != nil {
+				data.Error = "Internal Server Error: " + err.Error()
+				tmplCache["login_2fa.html"].ExecuteTemplate(w, "base.html", data)
+				return
+			}
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		entry.Outcome = audit.Failure
+		entry.Detail = "second factor: invalid code"
+		if auth.RecordLoginFailure(ip) {
+			entry.Detail += ", client locked out"
+			log.Printf("[AUTH] Locked out %s after repeated failed logins", ip)
+		}
+		audit.Record(entry)
+
+		data.Error = string(data.T("InvalidTwoFactorCode"))
+	}
+
+	tmplCache["login_2fa.html"].ExecuteTemplate(w, "base.html", data)
+}
+
+// BeginTwoFactor creates a pending TOTP secret for the signed-in user.
+func BeginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	if _, _, err := auth.BeginTOTPEnrollment(data.User); err != nil {
+		data.Error = err.Error()
+	}
+	renderSettings(w, data, settingsFlash{})
+}
+
+// ConfirmTwoFactor enables the pending secret once the user proves their app
+// generates matching codes, then shows the recovery codes.
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	codes, err := auth.ConfirmTOTPEnrollment(data.User, r.FormValue("code"))
+	recordAudit(r, audit.ActionTOTPEnable, data.User, err)
+	if err != nil {
+		data.Error = err.Error()
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+
+	data.Info = string(data.T("TwoFactorEnabled"))
+	renderSettings(w, data, settingsFlash{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns off the signed-in user's second factor. A valid code is
+// required so a hijacked session alone cannot remove it.
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	var err error
+	if !auth.VerifySecondFactor(data.User, r.FormValue("code")) {
+		err = errors.New(string(data.T("InvalidTwoFactorCode")))
+	} else {
+		auth.DisableTOTP(data.User)
+	}
+	recordAudit(r, audit.ActionTOTPDisable, data.User, err)
+	if err != nil {
+		data.Error = err.Error()
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+
+	data.Info = string(data.T("TwoFactorDisabled"))
+	renderSettings(w, data, settingsFlash{})
+}
+
+// ResetUserTwoFactor lets an admin remove another user's second factor, e.g. after
+// a lost phone with no recovery codes left.
+func ResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	username := r.FormValue("username")
+	if auth.DisableTOTP(username) {
+		recordAudit(r, audit.ActionTOTPDisable, username, nil)
+		data.Info = string(data.T("TwoFactorDisabled"))
+	}
+	renderSettings(w, data, settingsFlash{})
+}
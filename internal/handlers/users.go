@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/erysngl/zerostat/internal/audit"
+	"github.com/erysngl/zerostat/internal/auth"
+)
+
+// CreateUser adds a dashboard account from the settings page.
+func CreateUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	username := strings.TrimSpace(r.FormValue("username"))
+	err := auth.CreateUser(username, r.FormValue("user_password"), r.FormValue("role"))
+	recordAudit(r, audit.ActionUserCreate, username+" ("+r.FormValue("role")+")", err)
+	if err != nil {
+		data.Error = err.Error()
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+
+	data.Info = string(data.T("UserCreated"))
+	renderSettings(w, data, settingsFlash{})
+}
+
+// SetUserRole changes the role of an existing account.
+func SetUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	username := r.FormValue("username")
+	if username == data.User {
+		// Demoting yourself would lock you out of this very page
+		data.Error = string(data.T("CannotChangeOwnRole"))
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+	err := auth.SetUserRole(username, r.FormValue("role"))
+	recordAudit(r, audit.ActionUserRole, username+" -> "+r.FormValue("role"), err)
+	if err != nil {
+		data.Error = err.Error()
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+
+	data.Info = string(data.T("UserUpdated"))
+	renderSettings(w, data, settingsFlash{})
+}
+
+// DeleteUser removes an account; its open sessions are rejected from the next request.
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	username := r.FormValue("username")
+	if username == data.User {
+		data.Error = string(data.T("CannotDeleteSelf"))
+		renderSettings(w, data, settingsFlash{})
+		return
+	}
+	if auth.DeleteUser(username) {
+		recordAudit(r, audit.ActionUserDelete, username, nil)
+		data.Info = string(data.T("UserDeleted"))
+	}
+	renderSettings(w, data, settingsFlash{})
+}
+
+// ClearLockout lets an admin lift a login lockout before it expires.
+func ClearLockout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/settings", http.StatusFound)
+		return
+	}
+
+	data := getBaseData(r)
+	ip := r.FormValue("ip")
+	if auth.ClearLockout(ip) {
+		recordAudit(r, audit.ActionLockoutClear, ip, nil)
+		data.Info = string(data.T("LockoutCleared"))
+	}
+	renderSettings(w, data, settingsFlash{})
+}
@@ -0,0 +1,528 @@
+// Package incidents keeps the history of alert rules: every firing, repeat,
+// notification, shell action and recovery is appended as an event to a local
+// JSONL store, and events sharing an incident ID make up one incident.
+package incidents
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event kinds
+const (
+	KindFired        = "fired"        // the rule triggered, opening the incident
+	KindRepeat       = "repeat"       // the rule fired again after its cooldown
+	KindNotification = "notification" // a notification was dispatched
+	KindAction       = "action"       // the rule's shell command ran
+	KindRecovered    = "recovered"    // the condition cleared, resolving the incident
+	KindClosed       = "closed"       // resolved without recovering, e.g. the rule was disabled
+)
+
+// Outcomes of notifications and actions
+const (
+	Success = "success"
+	Failure = "failure"
+	Denied  = "denied"
+)
+
+// States for Filter
+const (
+	StateOpen     = "open"
+	StateResolved = "resolved"
+)
+
+// MaxOutput caps the shell command output kept per action event.
+const MaxOutput = 4096
+
+// Event is one thing that happened during an incident, stored as a single JSON line.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Incident  string    `json:"incident"`
+	RuleID    string    `json:"rule_id"`
+	Kind      string    `json:"kind"`
+	Condition string    `json:"condition,omitempty"` // the rule's condition, on fired events
+	Unit      string    `json:"unit,omitempty"`      // display suffix of Peak, on fired events
+	Value     string    `json:"value,omitempty"`     // observed value(s) with units
+	Peak      *float64  `json:"peak,omitempty"`      // worst value so far of single metric rules
+	Channel   string    `json:"channel,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	ExitCode  *int      `json:"exit_code,omitempty"`
+	Output    string    `json:"output,omitempty"`
+	Outcome   string    `json:"outcome,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Incident is a rule's stretch from firing to recovery with everything recorded
+// in between.
+type Incident struct {
+	ID            string
+	RuleID        string
+	Condition     string
+	Unit          string
+	Start         time.Time
+	End           *time.Time // nil while open
+	Peak          *float64
+	Notifications int // successfully sent, including the recovery notification
+	Repeats       int
+	Events        []Event // oldest first
+}
+
+// Open reports whether the incident has not been resolved yet.
+func (i *Incident) Open() bool {
+	return i.End == nil
+}
+
+// Duration is how long the incident lasted, or has lasted so far.
+func (i *Incident) Duration() time.Duration {
+	end := time.Now()
+	if i.End != nil {
+		end = *i.End
+	}
+	return end.Sub(i.Start).Round(time.Second)
+}
+
+// PeakText formats the peak with its unit, empty for expression rules.
+func (i *Incident) PeakText() string {
+	if i.Peak == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f%s", *i.Peak, i.Unit)
+}
+
+func (i *Incident) apply(e Event) {
+	switch e.Kind {
+	case KindFired:
+		i.Start, i.Condition, i.Unit = e.Time, e.Condition, e.Unit
+	case KindRepeat:
+		i.Repeats++
+	case KindNotification:
+		if e.Outcome == Success {
+			i.Notifications++
+		}
+	case KindRecovered, KindClosed:
+		if i.End == nil {
+			end := e.Time
+			i.End = &end
+		}
+	}
+	if e.Peak != nil {
+		i.Peak = e.Peak
+	}
+	i.Events = append(i.Events, e)
+}
+
+// openIncident is what the store remembers about a rule's unresolved incident.
+type openIncident struct {
+	id   string
+	peak *float64
+}
+
+// maxLoaded caps the incidents Query serves from memory. Past it the oldest
+// resolved ones are dropped from memory and Query reads the store instead.
+const maxLoaded = 5000
+
+var (
+	mu        sync.Mutex
+	file      *os.File
+	path      string
+	retention time.Duration
+	lastPrune time.Time
+	loaded    []*Incident // the newest incidents in the order they started
+	complete  bool        // loaded holds every incident of the store
+	byID      = make(map[string]*Incident)
+	open      = make(map[string]*openIncident) // by rule ID
+)
+
+// Init opens (creating if needed) the incident store for appending, drops the
+// incidents resolved longer than keep ago (0 keeps all) and picks up the
+// incidents a previous run left open.
+func Init(storePath string, keep time.Duration) error {
+	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return err
+	}
+	all, err := load(storePath)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+		file = nil
+	}
+	path, retention = storePath, keep
+	loaded, complete = all, true
+	byID = make(map[string]*Incident, len(all))
+	open = make(map[string]*openIncident)
+	for _, inc := range all {
+		byID[inc.ID] = inc
+		if inc.Open() {
+			open[inc.RuleID] = &openIncident{id: inc.ID, peak: inc.Peak}
+		}
+	}
+	trim()
+	prune(time.Now())
+
+	f, err := os.OpenFile(storePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	file = f
+	return nil
+}
+
+// SetRetention replaces how long resolved incidents are kept, applied on the
+// next prune.
+func SetRetention(keep time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	retention = keep
+	lastPrune = time.Time{}
+}
+
+// Start opens an incident for a rule that just fired and records e as its fired
+// event, e.Peak being the first value. A previous incident of the rule still
+// open is closed first. It returns the new incident's ID.
+func Start(e Event) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if prev, ok := open[e.RuleID]; ok {
+		write(Event{Incident: prev.id, RuleID: e.RuleID, Kind: KindClosed, Peak: prev.peak, Detail: "superseded by a new incident"})
+	}
+
+	e.Kind = KindFired
+	e.Incident = strconv.FormatInt(time.Now().UnixNano(), 10)
+	open[e.RuleID] = &openIncident{id: e.Incident, peak: e.Peak}
+	write(e)
+	return e.Incident
+}
+
+// Current returns the ID of the rule's open incident, empty if it has none.
+func Current(ruleID string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if inc, ok := open[ruleID]; ok {
+		return inc.id
+	}
+	return ""
+}
+
+// OpenRules lists the rules that have an open incident.
+func OpenRules() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	ids := make([]string, 0, len(open))
+	for id := range open {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Observe updates the peak of the rule's open incident with a new value. below
+// means the rule fires under its threshold, so the lowest value is the worst.
+func Observe(ruleID string, value float64, below bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	inc, ok := open[ruleID]
+	if !ok {
+		return
+	}
+	if inc.peak == nil || (below && value < *inc.peak) || (!below && value > *inc.peak) {
+		v := value
+		inc.peak = &v
+	}
+}
+
+// Record appends an event. Without an incident ID it belongs to the rule's open
+// incident and is dropped when there is none. Repeats carry the peak reached so
+// far, and recovered or closed events resolve the incident.
+func Record(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	inc, isOpen := open[e.RuleID]
+	if e.Incident == "" {
+		if !isOpen {
+			return
+		}
+		e.Incident = inc.id
+	}
+	if isOpen && inc.id == e.Incident {
+		switch e.Kind {
+		case KindRepeat:
+			e.Peak = inc.peak
+		case KindRecovered, KindClosed:
+			e.Peak = inc.peak
+			delete(open, e.RuleID)
+		}
+	}
+	write(e)
+}
+
+// Close resolves the rule's open incident, if any, without a recovery, e.g.
+// because the rule was disabled, changed or deleted.
+func Close(ruleID, reason string) {
+	Record(Event{RuleID: ruleID, Kind: KindClosed, Detail: reason})
+}
+
+// write appends e to the store. Failures are logged but never block alerting.
+// Callers hold mu.
+func write(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[INCIDENT] Failed to encode event: %v", err)
+		return
+	}
+	if file == nil {
+		log.Printf("[INCIDENT] %s", line)
+		return
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Printf("[INCIDENT] Failed to write event: %v", err)
+		return
+	}
+
+	inc, ok := byID[e.Incident]
+	if !ok {
+		inc = &Incident{ID: e.Incident, RuleID: e.RuleID, Start: e.Time}
+		byID[e.Incident] = inc
+		loaded = append(loaded, inc)
+		trim()
+	}
+	inc.apply(e)
+
+	if e.Time.Sub(lastPrune) >= 24*time.Hour {
+		prune(e.Time)
+	}
+}
+
+// trim drops the oldest resolved incidents from memory beyond maxLoaded.
+// Callers hold mu.
+func trim() {
+	excess := len(loaded) - maxLoaded
+	if excess <= 0 {
+		return
+	}
+	kept := loaded[:0]
+	for _, inc := range loaded {
+		if excess > 0 && !inc.Open() {
+			delete(byID, inc.ID)
+			excess--
+			continue
+		}
+		kept = append(kept, inc)
+	}
+	clear(loaded[len(kept):])
+	loaded, complete = kept, false
+}
+
+// prune rewrites the store without the incidents resolved before the retention,
+// the way the metrics store drops expired day files. Callers hold mu.
+func prune(now time.Time) {
+	lastPrune = now
+	if retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-retention)
+
+	expired := make(map[string]bool)
+	kept := loaded[:0]
+	for _, inc := range loaded {
+		if inc.End != nil && inc.End.Before(cutoff) {
+			expired[inc.ID] = true
+			delete(byID, inc.ID)
+			continue
+		}
+		kept = append(kept, inc)
+	}
+	clear(loaded[len(kept):])
+	loaded = kept
+	// Incidents no longer in memory are only found by reading the store
+	if len(expired) == 0 && complete {
+		return
+	}
+
+	reopen := file != nil
+	if reopen {
+		file.Close()
+		file = nil
+	}
+	if err := rewrite(path, cutoff); err != nil {
+		log.Printf("[INCIDENT] Failed to prune %s: %v", path, err)
+	}
+	if reopen {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Printf("[INCIDENT] Failed to reopen %s: %v", path, err)
+			return
+		}
+		file = f
+	}
+}
+
+// rewrite replaces the store with the events of the incidents still open or
+// resolved after cutoff, keeping their order.
+func rewrite(storePath string, cutoff time.Time) error {
+	all, err := load(storePath)
+	if err != nil {
+		return err
+	}
+	expired := make(map[string]bool)
+	for _, inc := range all {
+		if inc.End != nil && inc.End.Before(cutoff) {
+			expired[inc.ID] = true
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	in, err := os.Open(storePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := storePath + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e struct {
+			Incident string `json:"incident"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Incident == "" || expired[e.Incident] {
+			continue
+		}
+		w.Write(scanner.Bytes())
+		w.WriteByte('\n')
+	}
+	err = scanner.Err()
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, storePath)
+}
+
+// load reads the store and groups its events into incidents in the order they
+// started.
+func load(storePath string) ([]*Incident, error) {
+	fh, err := os.Open(storePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fh.Close()
+
+	var all []*Incident
+	byID := make(map[string]*Incident)
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Incident == "" {
+			continue // A torn final line from a crash must not hide the rest
+		}
+		inc, ok := byID[e.Incident]
+		if !ok {
+			inc = &Incident{ID: e.Incident, RuleID: e.RuleID, Start: e.Time}
+			byID[e.Incident] = inc
+			all = append(all, inc)
+		}
+		inc.apply(e)
+	}
+	return all, scanner.Err()
+}
+
+// snapshot returns every incident in the order they started, from memory when it
+// holds them all. The copies stay unchanged while later events come in.
+func snapshot() ([]*Incident, error) {
+	mu.Lock()
+	storePath, inMemory := path, complete
+	var all []*Incident
+	if inMemory {
+		all = make([]*Incident, len(loaded))
+		for i, inc := range loaded {
+			c := *inc
+			c.Events = c.Events[:len(c.Events):len(c.Events)]
+			all[i] = &c
+		}
+	}
+	mu.Unlock()
+
+	if storePath == "" {
+		return nil, fmt.Errorf("incident store not initialized")
+	}
+	if !inMemory {
+		return load(storePath) // read outside the lock so alerting never waits
+	}
+	return all, nil
+}
+
+// Filter selects incidents for Query. Empty fields match everything.
+type Filter struct {
+	RuleID string
+	State  string // StateOpen or StateResolved
+}
+
+func (f Filter) match(inc *Incident) bool {
+	if f.RuleID != "" && inc.RuleID != f.RuleID {
+		return false
+	}
+	switch f.State {
+	case StateOpen:
+		return inc.Open()
+	case StateResolved:
+		return !inc.Open()
+	}
+	return true
+}
+
+// Query returns matching incidents newest first, skipping offset and returning at
+// most limit of them (limit <= 0 means all), together with the total number of
+// matches.
+func Query(f Filter, offset, limit int) ([]*Incident, int, error) {
+	all, err := snapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+	var matched []*Incident
+	for i := len(all) - 1; i >= 0; i-- {
+		if f.match(all[i]) {
+			matched = append(matched, all[i])
+		}
+	}
+
+	total := len(matched)
+	if offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[offset:]
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total, nil
+}
@@ -0,0 +1,75 @@
+package incidents
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInitPrunesResolvedIncidents(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "incidents.jsonl")
+	old := time.Now().Add(-48 * time.Hour)
+	var lines []string
+	for _, e := range []Event{
+		{Time: old, Incident: "expired", RuleID: "cpu", Kind: KindFired},
+		{Time: old.Add(time.Minute), Incident: "still-open", RuleID: "ram", Kind: KindFired},
+		{Time: old.Add(2 * time.Minute), Incident: "expired", RuleID: "cpu", Kind: KindRecovered},
+		{Time: time.Now().Add(-time.Hour), Incident: "recent", RuleID: "cpu", Kind: KindFired},
+		{Time: time.Now().Add(-time.Minute), Incident: "recent", RuleID: "cpu", Kind: KindRecovered},
+	} {
+		line, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+	}
+	if err := os.WriteFile(storePath, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Init(storePath, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mu.Lock()
+		file.Close()
+		file, path = nil, ""
+		mu.Unlock()
+	}()
+
+	got, total, err := Query(Filter{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || got[0].ID != "recent" || got[1].ID != "still-open" {
+		t.Fatalf("Query = %d incidents %v, want recent and still-open", total, ids(got))
+	}
+	data, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"expired"`) {
+		t.Error("store still holds the events of the expired incident")
+	}
+
+	// Later events show up without reading the store again
+	Record(Event{RuleID: "ram", Kind: KindRecovered})
+	got, _, err = Query(Filter{State: StateOpen}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("open incidents after recovery = %v, want none", ids(got))
+	}
+}
+
+func ids(list []*Incident) []string {
+	var out []string
+	for _, inc := range list {
+		out = append(out, inc.ID)
+	}
+	return out
+}
@@ -0,0 +1,236 @@
+package metrics
+
+import "strings"
+
+// Threshold units. Each metric is collected in a native unit and accepts
+// thresholds in any unit of the same family.
+const (
+	UnitPercent = "%"
+	UnitKBps    = "KB/s"
+	UnitMBps    = "MB/s"
+	UnitMB      = "MB"
+	UnitGB      = "GB"
+	UnitPerSec  = "/s"
+	UnitMs      = "ms"
+	UnitCelsius = "°C"
+	UnitNone    = "-" // plain numbers such as load averages
+)
+
+// unitScale is how many native units make up one unit.
+var unitScale = map[string]float64{
+	UnitPercent: 1,
+	UnitKBps:    1,
+	UnitMBps:    1024,
+	UnitMB:      1 << 20,
+	UnitGB:      1 << 30,
+	UnitPerSec:  1,
+	UnitMs:      1,
+	UnitCelsius: 1,
+	UnitNone:    1,
+}
+
+// Metric is a value alert rules can target.
+type Metric struct {
+	Name  string   // MetricType of alert rules
+	Label string   // i18n key of the display name
+	Units []string // accepted threshold units, the first is the default
+
+	// Instances lists what "<Name>:<instance>" may name in a sample, e.g. the
+	// mountpoints for Disk. Nil for metrics without per-instance values.
+	Instances func(s *SystemStats) []string
+}
+
+// Catalog lists every alertable metric in display order.
+var Catalog = []Metric{
+	{Name: "CPU", Label: "MetricCPU", Units: []string{UnitPercent}, Instances: coreNames},
+	{Name: "CPUUser", Label: "MetricCPUUser", Units: []string{UnitPercent}},
+	{Name: "CPUSystem", Label: "MetricCPUSystem", Units: []string{UnitPercent}},
+	{Name: "CPUIowait", Label: "MetricCPUIowait", Units: []string{UnitPercent}},
+	{Name: "CPUSteal", Label: "MetricCPUSteal", Units: []string{UnitPercent}},
+	{Name: "Load1", Label: "MetricLoad1", Units: []string{UnitNone}},
+	{Name: "Load5", Label: "MetricLoad5", Units: []string{UnitNone}},
+	{Name: "Load15", Label: "MetricLoad15", Units: []string{UnitNone}},
+	{Name: "CtxSwitch", Label: "MetricCtxSwitch", Units: []string{UnitPerSec}},
+	{Name: "RAM", Label: "MetricRAM", Units: []string{UnitPercent}},
+	{Name: "RAMAvail", Label: "MetricRAMAvail", Units: []string{UnitMB, UnitGB}},
+	{Name: "Swap", Label: "MetricSwap", Units: []string{UnitPercent}},
+	{Name: "PSICPUSome", Label: "MetricPSICPUSome", Units: []string{UnitPercent}},
+	{Name: "PSIMemorySome", Label: "MetricPSIMemorySome", Units: []string{UnitPercent}},
+	{Name: "PSIMemoryFull", Label: "MetricPSIMemoryFull", Units: []string{UnitPercent}},
+	{Name: "PSIIOSome", Label: "MetricPSIIOSome", Units: []string{UnitPercent}},
+	{Name: "PSIIOFull", Label: "MetricPSIIOFull", Units: []string{UnitPercent}},
+	{Name: "Disk", Label: "MetricDisk", Units: []string{UnitPercent}, Instances: mountpoints},
+	{Name: "DiskInodes", Label: "MetricDiskInodes", Units: []string{UnitPercent}, Instances: mountpoints},
+	{Name: "DiskRead", Label: "MetricDiskRead", Units: []string{UnitKBps, UnitMBps}, Instances: blockDevices},
+	{Name: "DiskWrite", Label: "MetricDiskWrite", Units: []string{UnitKBps, UnitMBps}, Instances: blockDevices},
+	{Name: "DiskIOPS", Label: "MetricDiskIOPS", Units: []string{UnitPerSec}, Instances: blockDevices},
+	{Name: "DiskAwait", Label: "MetricDiskAwait", Units: []string{UnitMs}, Instances: blockDevices},
+	{Name: "DiskUtil", Label: "MetricDiskUtil", Units: []string{UnitPercent}, Instances: blockDevices},
+	{Name: "Temp", Label: "MetricTemp", Units: []string{UnitCelsius}, Instances: sensorNames},
+	{Name: "NetRx", Label: "MetricNetRx", Units: []string{UnitKBps, UnitMBps}, Instances: interfaceNames},
+	{Name: "NetTx", Label: "MetricNetTx", Units: []string{UnitKBps, UnitMBps}, Instances: interfaceNames},
+	{Name: "NetRxTotal", Label: "MetricNetRxTotal", Units: []string{UnitMB, UnitGB}, Instances: interfaceNames},
+	{Name: "NetTxTotal", Label: "MetricNetTxTotal", Units: []string{UnitMB, UnitGB}, Instances: interfaceNames},
+}
+
+func coreNames(s *SystemStats) []string {
+	names := make([]string, len(s.CPUPerCore))
+	for i := range s.CPUPerCore {
+		names[i] = coreName(i)
+	}
+	return names
+}
+
+func interfaceNames(s *SystemStats) []string {
+	names := make([]string, len(s.Interfaces))
+	for i, iface := range s.Interfaces {
+		names[i] = iface.Name
+	}
+	return names
+}
+
+func blockDevices(s *SystemStats) []string {
+	names := make([]string, len(s.DiskIO))
+	for i, d := range s.DiskIO {
+		names[i] = d.Name
+	}
+	return names
+}
+
+func sensorNames(s *SystemStats) []string {
+	names := make([]string, len(s.Temperatures))
+	for i, sensor := range s.Temperatures {
+		names[i] = sensor.Name
+	}
+	return names
+}
+
+func mountpoints(s *SystemStats) []string {
+	names := make([]string, len(s.Disks))
+	for i, d := range s.Disks {
+		names[i] = d.Mountpoint
+	}
+	return names
+}
+
+// SplitInstance separates a rule's MetricType into the catalog name and the
+// optional instance, e.g. "Disk:/var" into "Disk" and "/var".
+func SplitInstance(name string) (string, string) {
+	base, instance, _ := strings.Cut(name, ":")
+	return base, instance
+}
+
+// LookupMetric finds a catalog entry by name, which may carry an instance suffix
+// for metrics that support one.
+func LookupMetric(name string) (Metric, bool) {
+	name, instance := SplitInstance(name)
+	for _, m := range Catalog {
+		if m.Name == name {
+			if instance != "" && m.Instances == nil {
+				return Metric{}, false
+			}
+			return m, true
+		}
+	}
+	return Metric{}, false
+}
+
+// Target is one selectable alert rule metric: a catalog entry, optionally bound
+// to an instance such as a mountpoint or core.
+type Target struct {
+	Metric
+	Instance string
+}
+
+// Value is the MetricType an alert rule stores for the target.
+func (t Target) Value() string {
+	if t.Instance == "" {
+		return t.Name
+	}
+	return t.Name + ":" + t.Instance
+}
+
+// Targets lists the catalog followed by an entry for every instance in the
+// latest sample.
+func Targets() []Target {
+	targets := make([]Target, 0, len(Catalog))
+	for _, m := range Catalog {
+		targets = append(targets, Target{Metric: m})
+	}
+	latest := Latest()
+	for _, m := range Catalog {
+		if m.Instances == nil {
+			continue
+		}
+		for _, instance := range m.Instances(latest) {
+			targets = append(targets, Target{Metric: m, Instance: instance})
+		}
+	}
+	return targets
+}
+
+// AcceptsUnit reports whether thresholds of m may be given in unit.
+func (m Metric) AcceptsUnit(unit string) bool {
+	for _, u := range m.Units {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// Value returns the named metric converted to unit. An empty unit means the
+// metric's default unit.
+func (s *SystemStats) Value(name, unit string) (float64, bool) {
+	var native float64
+	base, instance := SplitInstance(name)
+	switch base {
+	case "NetRxTotal", "NetTxTotal":
+		rx, tx := s.NetRx, s.NetTx
+		if instance != "" {
+			iface, ok := s.Interface(instance)
+			if !ok {
+				return 0, false
+			}
+			rx, tx = iface.RxBytes, iface.TxBytes
+		}
+		native = float64(rx)
+		if base == "NetTxTotal" {
+			native = float64(tx)
+		}
+	default:
+		v, ok := s.Series()[name]
+		if !ok {
+			return 0, false
+		}
+		native = v
+	}
+	return convert(name, unit, native), true
+}
+
+// convert turns a native value of the named metric into unit, an empty unit
+// meaning the metric's default.
+func convert(name, unit string, native float64) float64 {
+	if unit == "" {
+		if m, ok := LookupMetric(name); ok {
+			unit = m.Units[0]
+		}
+	}
+	scale, ok := unitScale[unit]
+	if !ok {
+		return native
+	}
+	return native / scale
+}
+
+// UnitSuffix formats unit for display after a number: "%" sticks to the value,
+// other units are separated by a space.
+func UnitSuffix(unit string) string {
+	if unit == "" || unit == UnitPercent {
+		return UnitPercent
+	}
+	if unit == UnitNone {
+		return ""
+	}
+	return " " + unit
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	latestMu sync.RWMutex
+	latest   *SystemStats
+
+	collectorOnce sync.Once
+	collectorStop chan struct{}
+	collectorDone chan struct{}
+)
+
+// StartCollector launches the single sampler goroutine. Every interval it takes a
+// snapshot, records it into the history ring and store, and publishes it for
+// Latest. Readers never trigger gopsutil calls themselves.
+func StartCollector(interval time.Duration) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	collectorOnce.Do(func() {
+		publish(collect())
+
+		collectorStop = make(chan struct{})
+		collectorDone = make(chan struct{})
+		go func() {
+			defer close(collectorDone)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					publish(collect())
+				case <-collectorStop:
+					return
+				}
+			}
+		}()
+		log.Printf("Metrics collector sampling every %s", interval)
+	})
+}
+
+// StopCollector stops the sampler, waiting for a running sample to be recorded,
+// so the history store can be closed afterwards.
+func StopCollector() {
+	if collectorStop == nil {
+		return
+	}
+	close(collectorStop)
+	<-collectorDone
+	collectorStop = nil
+}
+
+func publish(stats *SystemStats) {
+	latestMu.Lock()
+	latest = stats
+	latestMu.Unlock()
+}
+
+// Latest returns the most recent snapshot produced by the collector. Callers must
+// treat it as read-only since it is shared between handlers and the alert engine.
+func Latest() *SystemStats {
+	latestMu.RLock()
+	s := latest
+	latestMu.RUnlock()
+
+	if s == nil {
+		// Collector not started yet, hand out an empty snapshot rather than sampling here
+		return &SystemStats{Timestamp: time.Now(), CPUCores: cores}
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+var (
+	lastCPUTimes cpu.TimesStat
+	lastCtxt     uint64
+	lastCPUTime  time.Time
+)
+
+// coreName is the instance name of a core in per-core series, as in /proc/stat.
+func coreName(i int) string {
+	return "cpu" + strconv.Itoa(i)
+}
+
+// cpuTotal sums the jiffies of t. Guest time is already part of user time on
+// Linux, so it is left out to avoid counting it twice.
+func cpuTotal(t cpu.TimesStat) float64 {
+	return t.User + t.Nice + t.System + t.Idle + t.Iowait + t.Irq + t.Softirq + t.Steal
+}
+
+// collectCPU fills the per-core usage, time breakdown, load averages and context
+// switch rate. Like the network rates, the breakdown and context switches are
+// deltas against the previous sample, so the first sample reports zero.
+func collectCPU(stats *SystemStats) {
+	if perCore, err := cpu.Percent(0, true); err == nil {
+		stats.CPUPerCore = perCore
+	}
+
+	if avg, err := load.Avg(); err == nil {
+		stats.Load1 = avg.Load1
+		stats.Load5 = avg.Load5
+		stats.Load15 = avg.Load15
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+
+	if times, err := cpu.Times(false); err == nil && len(times) > 0 {
+		t := times[0]
+		if elapsed := cpuTotal(t) - cpuTotal(lastCPUTimes); !lastCPUTime.IsZero() && elapsed > 0 {
+			pct := func(cur, prev float64) float64 { return (cur - prev) / elapsed * 100 }
+			stats.CPUUser = pct(t.User+t.Nice, lastCPUTimes.User+lastCPUTimes.Nice)
+			stats.CPUSystem = pct(t.System+t.Irq+t.Softirq, lastCPUTimes.System+lastCPUTimes.Irq+lastCPUTimes.Softirq)
+			stats.CPUIowait = pct(t.Iowait, lastCPUTimes.Iowait)
+			stats.CPUSteal = pct(t.Steal, lastCPUTimes.Steal)
+		}
+		lastCPUTimes = t
+	}
+
+	if misc, err := load.Misc(); err == nil {
+		ctxt := uint64(misc.Ctxt)
+		if !lastCPUTime.IsZero() && ctxt >= lastCtxt {
+			if duration := now.Sub(lastCPUTime).Seconds(); duration > 0 {
+				stats.CtxSwitches = float64(ctxt-lastCtxt) / duration
+			}
+		}
+		lastCtxt = ctxt
+	}
+
+	lastCPUTime = now
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DiskDevice is the I/O activity of one block device since the previous sample.
+type DiskDevice struct {
+	Name       string  `json:"name"`
+	ReadSpeed  float64 `json:"read_speed"`  // KB/s
+	WriteSpeed float64 `json:"write_speed"` // KB/s
+	ReadIOPS   float64 `json:"read_iops"`
+	WriteIOPS  float64 `json:"write_iops"`
+	Await      float64 `json:"await"` // average milliseconds per completed request
+	Util       float64 `json:"util"`  // percent of time the device was busy
+}
+
+// IOPS is the combined read and write request rate.
+func (d DiskDevice) IOPS() float64 {
+	return d.ReadIOPS + d.WriteIOPS
+}
+
+// ignoredBlockDevices are pseudo devices without physical I/O worth watching.
+var ignoredBlockDevices = []string{"loop*", "ram*"}
+
+var (
+	lastDiskIO     map[string]disk.IOCountersStat
+	lastDiskIOTime time.Time
+)
+
+// isWholeDisk reports whether name is a block device rather than a partition of
+// one. Partitions are skipped so their I/O is not counted twice. When sysfs is
+// not readable every device is accepted.
+func isWholeDisk(name string) bool {
+	readable := false
+	for _, sys := range []string{"/host/sys/block", "/sys/block"} {
+		if _, err := os.Stat(sys); err != nil {
+			continue
+		}
+		readable = true
+		if _, err := os.Stat(filepath.Join(sys, name)); err == nil {
+			return true
+		}
+	}
+	return !readable
+}
+
+// collectDiskIO fills the per-device I/O rates and their totals. Like the
+// network rates they are deltas against the previous sample, so the first
+// sample and newly attached devices report zero.
+func collectDiskIO(stats *SystemStats) {
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	seconds := now.Sub(lastDiskIOTime).Seconds()
+	current := make(map[string]disk.IOCountersStat, len(counters))
+
+	var ops, opTime uint64
+	for name, c := range counters {
+		if matchAny(ignoredBlockDevices, name) || !isWholeDisk(name) {
+			continue
+		}
+		current[name] = c
+
+		dev := DiskDevice{Name: name}
+		if prev, ok := lastDiskIO[name]; ok && !lastDiskIOTime.IsZero() {
+			dev.ReadSpeed = rate(c.ReadBytes, prev.ReadBytes, seconds) / 1024
+			dev.WriteSpeed = rate(c.WriteBytes, prev.WriteBytes, seconds) / 1024
+			dev.ReadIOPS = rate(c.ReadCount, prev.ReadCount, seconds)
+			dev.WriteIOPS = rate(c.WriteCount, prev.WriteCount, seconds)
+			// IoTime counts milliseconds with requests in flight, so per second of
+			// wall time it is the busy share
+			dev.Util = rate(c.IoTime, prev.IoTime, seconds) / 10
+			if dev.Util > 100 {
+				dev.Util = 100
+			}
+
+			if c.ReadCount+c.WriteCount > prev.ReadCount+prev.WriteCount && c.ReadTime+c.WriteTime >= prev.ReadTime+prev.WriteTime {
+				n := c.ReadCount + c.WriteCount - prev.ReadCount - prev.WriteCount
+				t := c.ReadTime + c.WriteTime - prev.ReadTime - prev.WriteTime
+				dev.Await = float64(t) / float64(n)
+				ops += n
+				opTime += t
+			}
+		}
+		stats.DiskIO = append(stats.DiskIO, dev)
+
+		stats.DiskRead += dev.ReadSpeed
+		stats.DiskWrite += dev.WriteSpeed
+		stats.DiskIOPS += dev.IOPS()
+		if dev.Util > stats.DiskUtil {
+			stats.DiskUtil = dev.Util
+		}
+	}
+	if ops > 0 {
+		stats.DiskAwait = float64(opTime) / float64(ops)
+	}
+	sort.Slice(stats.DiskIO, func(i, j int) bool { return stats.DiskIO[i].Name < stats.DiskIO[j].Name })
+
+	lastDiskIO = current
+	lastDiskIOTime = now
+}
+
+// Device returns the I/O stats of the block device called name.
+func (s *SystemStats) Device(name string) (DiskDevice, bool) {
+	for _, d := range s.DiskIO {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return DiskDevice{}, false
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// DiskMount is the capacity and inode usage of one mounted filesystem.
+type DiskMount struct {
+	Mountpoint  string  `json:"mountpoint"`
+	Device      string  `json:"device"`
+	FSType      string  `json:"fstype"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Usage       float64 `json:"usage"`
+	InodesTotal uint64  `json:"inodes_total"`
+	InodesUsed  uint64  `json:"inodes_used"`
+	InodesUsage float64 `json:"inodes_usage"`
+}
+
+// defaultHostRoot is where docker-compose.yml mounts the host's / read-only.
+const defaultHostRoot = "/host/root"
+
+var (
+	diskFilterMu sync.RWMutex
+	hostRoot     string
+	diskInclude  []string
+	diskExclude  []string
+)
+
+// SetDiskFilter configures which filesystems are reported. Mountpoints are read from
+// the host's mount table; root is the directory the host's / is visible under
+// ("" auto-detects /host/root). include and exclude are path.Match patterns on the
+// host mountpoint; an empty include list keeps everything not excluded.
+func SetDiskFilter(root string, include, exclude []string) {
+	if root == "" {
+		if info, err := os.Stat(defaultHostRoot); err == nil && info.IsDir() {
+			root = defaultHostRoot
+		}
+	}
+	diskFilterMu.Lock()
+	defer diskFilterMu.Unlock()
+	hostRoot = root
+	diskInclude = include
+	diskExclude = exclude
+}
+
+func matchAny(patterns []string, mountpoint string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, mountpoint); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// collectDisks lists physical filesystems, one entry per device (bind mounts of the
+// same device are skipped in favour of the shortest mountpoint), sorted by mountpoint.
+func collectDisks() []DiskMount {
+	diskFilterMu.RLock()
+	root, include, exclude := hostRoot, diskInclude, diskExclude
+	diskFilterMu.RUnlock()
+
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		return len(partitions[i].Mountpoint) < len(partitions[j].Mountpoint)
+	})
+
+	seen := make(map[string]bool)
+	mounts := make([]DiskMount, 0, len(partitions))
+	for _, p := range partitions {
+		if seen[p.Device] {
+			continue
+		}
+		if len(include) > 0 && !matchAny(include, p.Mountpoint) {
+			continue
+		}
+		if matchAny(exclude, p.Mountpoint) {
+			continue
+		}
+
+		usage, err := disk.Usage(filepath.Join(root, p.Mountpoint))
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+		seen[p.Device] = true
+		mounts = append(mounts, DiskMount{
+			Mountpoint:  p.Mountpoint,
+			Device:      p.Device,
+			FSType:      p.Fstype,
+			Total:       usage.Total,
+			Used:        usage.Used,
+			Usage:       usage.UsedPercent,
+			InodesTotal: usage.InodesTotal,
+			InodesUsed:  usage.InodesUsed,
+			InodesUsage: usage.InodesUsedPercent,
+		})
+	}
+
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Mountpoint < mounts[j].Mountpoint })
+	return mounts
+}
+
+// rootDisk is the usage of the host's / when it is among the reported mounts,
+// falling back to the filesystem ZeroStat itself runs on.
+func rootDisk(mounts []DiskMount) (DiskMount, bool) {
+	for _, m := range mounts {
+		if m.Mountpoint == "/" {
+			return m, true
+		}
+	}
+
+	diskFilterMu.RLock()
+	root := hostRoot
+	diskFilterMu.RUnlock()
+	if root == "" {
+		root = "/"
+	}
+	usage, err := disk.Usage(root)
+	if err != nil {
+		return DiskMount{}, false
+	}
+	return DiskMount{
+		Mountpoint:  "/",
+		FSType:      usage.Fstype,
+		Total:       usage.Total,
+		Used:        usage.Used,
+		Usage:       usage.UsedPercent,
+		InodesTotal: usage.InodesTotal,
+		InodesUsed:  usage.InodesUsed,
+		InodesUsage: usage.InodesUsedPercent,
+	}, true
+}
+
+// Mount returns the reported filesystem mounted at mountpoint.
+func (s *SystemStats) Mount(mountpoint string) (DiskMount, bool) {
+	for _, m := range s.Disks {
+		if m.Mountpoint == mountpoint {
+			return m, true
+		}
+	}
+	return DiskMount{}, false
+}
+
+// SplitPatterns parses a comma separated pattern list from configuration.
+func SplitPatterns(raw string) []string {
+	var out []string
+	for _, p := range strings.Split(raw, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
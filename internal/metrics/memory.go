@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// Pressure is one line pair of /proc/pressure/<resource>: the share of wall time
+// in percent during which some (or all, for full) runnable tasks were stalled on
+// the resource, averaged over 10, 60 and 300 seconds.
+type Pressure struct {
+	Some10  float64 `json:"some_avg10"`
+	Some60  float64 `json:"some_avg60"`
+	Some300 float64 `json:"some_avg300"`
+	Full10  float64 `json:"full_avg10"`
+	Full60  float64 `json:"full_avg60"`
+	Full300 float64 `json:"full_avg300"`
+}
+
+// pressureResources are the PSI files read from /proc/pressure, in display order.
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// pressureSeries is the series name prefix of each resource. Alert rules and the
+// history use the 10 second averages, e.g. PSIMemorySome and PSIMemoryFull. The
+// kernel's cpu "full" line is always zero, so only PSICPUSome exists.
+var pressureSeries = map[string]string{"cpu": "PSICPU", "memory": "PSIMemory", "io": "PSIIO"}
+
+// pressureLabels are the i18n keys of the resource names on the dashboard.
+var pressureLabels = map[string]string{"cpu": "PressureCPU", "memory": "PressureMemory", "io": "PressureIO"}
+
+// collectMemory fills physical memory, its breakdown and swap.
+func collectMemory(stats *SystemStats) {
+	if v, err := mem.VirtualMemory(); err == nil {
+		stats.MemTotal = v.Total
+		stats.MemUsed = v.Used
+		stats.MemUsage = v.UsedPercent
+		stats.MemAvailable = v.Available
+		stats.MemCached = v.Cached
+		stats.MemBuffers = v.Buffers
+	}
+
+	if sw, err := mem.SwapMemory(); err == nil {
+		stats.SwapTotal = sw.Total
+		stats.SwapUsed = sw.Used
+		stats.SwapUsage = sw.UsedPercent
+	}
+
+	stats.Pressure = readPressure()
+}
+
+// readPressure parses PSI for every resource, preferring the host's procfs when it
+// is mounted into the container. It returns nil on kernels without PSI (before 4.20
+// or booted with psi=0).
+func readPressure() map[string]Pressure {
+	var pressure map[string]Pressure
+	for _, resource := range pressureResources {
+		for _, dir := range []string{"/host/proc/pressure", "/proc/pressure"} {
+			p, err := parsePressure(filepath.Join(dir, resource))
+			if err != nil {
+				continue
+			}
+			if pressure == nil {
+				pressure = make(map[string]Pressure, len(pressureResources))
+			}
+			pressure[resource] = p
+			break
+		}
+	}
+	return pressure
+}
+
+// parsePressure reads lines like "some avg10=0.12 avg60=0.05 avg300=0.01 total=1234".
+func parsePressure(path string) (Pressure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Pressure{}, err
+	}
+	defer f.Close()
+
+	var p Pressure
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		avg := make(map[string]float64, 3)
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				avg[key] = v
+			}
+		}
+		switch fields[0] {
+		case "some":
+			p.Some10, p.Some60, p.Some300 = avg["avg10"], avg["avg60"], avg["avg300"]
+		case "full":
+			p.Full10, p.Full60, p.Full300 = avg["avg10"], avg["avg60"], avg["avg300"]
+		}
+	}
+	return p, scanner.Err()
+}
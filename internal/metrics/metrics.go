@@ -5,39 +5,164 @@ import (
 	"sync"
 	"time"
 
+	"github.com/erysngl/zerostat/internal/tsdb"
 	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
-	"github.com/shirou/gopsutil/v3/net"
 )
 
 type SystemStats struct {
-	CPUUsage     float64
-	CPUCores     int
-	MemTotal     uint64
-	MemUsed      uint64
-	MemUsage     float64
-	DiskTotal    uint64
-	DiskUsed     uint64
-	DiskUsage    float64
-	NetRx        uint64
-	NetTx        uint64
-	NetRxSpeed   float64 // KB/s
-	NetTxSpeed   float64 // KB/s
+	Timestamp    time.Time           `json:"timestamp"`
+	CPUUsage     float64             `json:"cpu_usage"`
+	CPUCores     int                 `json:"cpu_cores"`
+	CPUPerCore   []float64           `json:"cpu_per_core"`
+	CPUUser      float64             `json:"cpu_user"`
+	CPUSystem    float64             `json:"cpu_system"`
+	CPUIowait    float64             `json:"cpu_iowait"`
+	CPUSteal     float64             `json:"cpu_steal"`
+	Load1        float64             `json:"load1"`
+	Load5        float64             `json:"load5"`
+	Load15       float64             `json:"load15"`
+	CtxSwitches  float64             `json:"ctx_switches"` // per second
+	MemTotal     uint64              `json:"mem_total"`
+	MemUsed      uint64              `json:"mem_used"`
+	MemUsage     float64             `json:"mem_usage"`
+	MemAvailable uint64              `json:"mem_available"`
+	MemCached    uint64              `json:"mem_cached"`
+	MemBuffers   uint64              `json:"mem_buffers"`
+	SwapTotal    uint64              `json:"swap_total"`
+	SwapUsed     uint64              `json:"swap_used"`
+	SwapUsage    float64             `json:"swap_usage"`
+	Pressure     map[string]Pressure `json:"pressure,omitempty"` // keyed cpu, memory, io
+	DiskTotal    uint64              `json:"disk_total"`
+	DiskUsed     uint64              `json:"disk_used"`
+	DiskUsage    float64             `json:"disk_usage"`
+	DiskInodes   float64             `json:"disk_inodes_usage"`
+	DiskRead     float64             `json:"disk_read_speed"`  // KB/s over all devices
+	DiskWrite    float64             `json:"disk_write_speed"` // KB/s over all devices
+	DiskIOPS     float64             `json:"disk_iops"`
+	DiskAwait    float64             `json:"disk_await"` // ms, averaged over all requests
+	DiskUtil     float64             `json:"disk_util"`  // percent, busiest device
+	NetRx        uint64              `json:"net_rx"`
+	NetTx        uint64              `json:"net_tx"`
+	NetRxSpeed   float64             `json:"net_rx_speed"` // KB/s
+	NetTxSpeed   float64             `json:"net_tx_speed"` // KB/s
+	Interfaces   []NetInterface      `json:"interfaces"`
+	Disks        []DiskMount         `json:"disks"`
+	DiskIO       []DiskDevice        `json:"disk_io"`
+	TempMax      float64             `json:"temp_max"` // °C, hottest sensor
+	Temperatures []Sensor            `json:"temperatures"`
+}
+
+// Series flattens the stats into the named values persisted by the history store.
+// Names match the MetricType values understood by alert rules.
+// Per-instance values are keyed "<name>:<instance>", e.g. "Disk:/var" or "CPU:cpu0".
+func (s *SystemStats) Series() map[string]float64 {
+	series := map[string]float64{
+		"CPU":        s.CPUUsage,
+		"CPUUser":    s.CPUUser,
+		"CPUSystem":  s.CPUSystem,
+		"CPUIowait":  s.CPUIowait,
+		"CPUSteal":   s.CPUSteal,
+		"Load1":      s.Load1,
+		"Load5":      s.Load5,
+		"Load15":     s.Load15,
+		"CtxSwitch":  s.CtxSwitches,
+		"RAM":        s.MemUsage,
+		"RAMAvail":   float64(s.MemAvailable),
+		"Swap":       s.SwapUsage,
+		"Disk":       s.DiskUsage,
+		"DiskInodes": s.DiskInodes,
+		"DiskRead":   s.DiskRead,
+		"DiskWrite":  s.DiskWrite,
+		"DiskIOPS":   s.DiskIOPS,
+		"DiskAwait":  s.DiskAwait,
+		"DiskUtil":   s.DiskUtil,
+		"NetRx":      s.NetRxSpeed,
+		"NetTx":      s.NetTxSpeed,
+	}
+	for resource, p := range s.Pressure {
+		name := pressureSeries[resource]
+		series[name+"Some"] = p.Some10
+		if resource != "cpu" {
+			series[name+"Full"] = p.Full10
+		}
+	}
+	for i, pct := range s.CPUPerCore {
+		series["CPU:"+coreName(i)] = pct
+	}
+	for _, iface := range s.Interfaces {
+		series["NetRx:"+iface.Name] = iface.RxSpeed
+		series["NetTx:"+iface.Name] = iface.TxSpeed
+	}
+	if len(s.Temperatures) > 0 {
+		series["Temp"] = s.TempMax
+	}
+	for _, sensor := range s.Temperatures {
+		series["Temp:"+sensor.Name] = sensor.Celsius
+	}
+	for _, d := range s.DiskIO {
+		series["DiskRead:"+d.Name] = d.ReadSpeed
+		series["DiskWrite:"+d.Name] = d.WriteSpeed
+		series["DiskIOPS:"+d.Name] = d.IOPS()
+		series["DiskAwait:"+d.Name] = d.Await
+		series["DiskUtil:"+d.Name] = d.Util
+	}
+	for _, m := range s.Disks {
+		series["Disk:"+m.Mountpoint] = m.Usage
+		series["DiskInodes:"+m.Mountpoint] = m.InodesUsage
+	}
+	return series
+}
+
+// historySeries is the part of Series recorded into the history store: the
+// aggregates, the per-interface network rates and the instances asked for with
+// KeepHistory. Every persisted instance adds a value to each raw line and rollup
+// bucket, so on hosts with many cores, devices, mounts or sensors the rest only
+// lives in the in-memory ring.
+func (s *SystemStats) historySeries() map[string]float64 {
+	keepMu.RLock()
+	defer keepMu.RUnlock()
+
+	series := s.Series()
+	for name := range series {
+		base, instance := SplitInstance(name)
+		// Interfaces are charted from the store; other instances only feed trend rules
+		if instance == "" || base == "NetRx" || base == "NetTx" || keepHistory[name] {
+			continue
+		}
+		delete(series, name)
+	}
+	return series
+}
+
+var (
+	keepMu      sync.RWMutex
+	keepHistory map[string]bool
+)
+
+// KeepHistory sets the per-instance series, such as "Disk:/var", that are
+// recorded into the history store besides those historySeries always keeps.
+func KeepHistory(names []string) {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	keepMu.Lock()
+	defer keepMu.Unlock()
+	keepHistory = keep
 }
 
 const historySize = 60
 
 var (
-	lastNetRx uint64
-	lastNetTx uint64
-	lastTime  time.Time
-	mu        sync.Mutex
+	lastTime time.Time
+	mu       sync.Mutex
 	
 	historyMutex sync.RWMutex
 	historyIndex int
 	historyList  [historySize]*SystemStats
 	cores        int
+
+	store *tsdb.Store
 )
 
 func init() {
@@ -53,10 +178,35 @@ func formatMB(bytes uint64) float64 {
 	return float64(bytes) / 1024 / 1024
 }
 
-// GetStats compiles current system metrics.
-func GetStats() *SystemStats {
+// SetStore attaches the persistent history store every sample gets recorded into.
+func SetStore(s *tsdb.Store) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	store = s
+}
+
+// QueryHistory reads persisted samples between from and to. An empty resolution
+// picks a tier suited to the span.
+func QueryHistory(from, to time.Time, res tsdb.Resolution) ([]tsdb.Point, error) {
+	historyMutex.RLock()
+	s := store
+	historyMutex.RUnlock()
+
+	if s == nil {
+		return nil, fmt.Errorf("history store not initialized")
+	}
+	if res == "" {
+		res = tsdb.PickResolution(to.Sub(from))
+	}
+	return s.Query(from, to, res)
+}
+
+// collect samples current system metrics. It is only called by the collector
+// goroutine so the delta based rates (network) see a single, regular caller.
+func collect() *SystemStats {
 	stats := &SystemStats{
-		CPUCores: cores,
+		Timestamp: time.Now(),
+		CPUCores:  cores,
 	}
 
 	// CPU
@@ -64,20 +214,18 @@ func GetStats() *SystemStats {
 	if err == nil && len(pcts) > 0 {
 		stats.CPUUsage = pcts[0]
 	}
+	collectCPU(stats)
 
 	// Memory
-	v, err := mem.VirtualMemory()
-	if err == nil {
-		stats.MemTotal = v.Total
-		stats.MemUsed = v.Used
-		stats.MemUsage = v.UsedPercent
-	}
+	collectMemory(stats)
 
 	// Disk
-	d, err := disk.Usage("/")
-	if err == nil {
+	stats.Disks = collectDisks()
+	d, ok := rootDisk(stats.Disks)
+	if ok {
 		stats.DiskTotal = d.Total
 		stats.DiskUsed = d.Used
+		stats.DiskInodes = d.InodesUsage
 		if d.Total > 0 {
 			stats.DiskUsage = (float64(d.Used) / float64(d.Total)) * 100
 		} else {
@@ -85,42 +233,48 @@ func GetStats() *SystemStats {
 		}
 	}
 
-	// Network
-	mu.Lock()
-	defer mu.Unlock()
-
-	netStats, err := net.IOCounters(false)
-	if err == nil && len(netStats) > 0 {
-		currentRx := netStats[0].BytesRecv
-		currentTx := netStats[0].BytesSent
-		now := time.Now()
+	collectDiskIO(stats)
 
-		if !lastTime.IsZero() {
-			duration := now.Sub(lastTime).Seconds()
-			if duration > 0 {
-				rxDiff := currentRx - lastNetRx
-				txDiff := currentTx - lastNetTx
-				stats.NetRxSpeed = float64(rxDiff) / duration / 1024 // KB/s
-				stats.NetTxSpeed = float64(txDiff) / duration / 1024 // KB/s
-			}
+	// Temperatures
+	stats.Temperatures = collectSensors()
+	for _, sensor := range stats.Temperatures {
+		if sensor.Celsius > stats.TempMax {
+			stats.TempMax = sensor.Celsius
 		}
-
-		stats.NetRx = currentRx
-		stats.NetTx = currentTx
-		lastNetRx = currentRx
-		lastNetTx = currentTx
-		lastTime = now
 	}
 
+	// Network
+	collectNetwork(stats)
+
 	// Add to history
 	historyMutex.Lock()
 	historyList[historyIndex] = stats
 	historyIndex = (historyIndex + 1) % historySize
+	s := store
 	historyMutex.Unlock()
 
+	if s != nil {
+		s.Append(stats.Timestamp, stats.historySeries())
+	}
+
 	return stats
 }
 
+// History returns the in-memory ring buffer in chronological order.
+func History() []*SystemStats {
+	historyMutex.RLock()
+	defer historyMutex.RUnlock()
+
+	ordered := make([]*SystemStats, 0, historySize)
+	for i := 0; i < historySize; i++ {
+		idx := (historyIndex + i) % historySize
+		if historyList[idx] != nil {
+			ordered = append(ordered, historyList[idx])
+		}
+	}
+	return ordered
+}
+
 // GeneratePoints creates a space-separated string of points for an SVG polyline.
 // maxVal is the highest expected value (e.g., 100 for percentages).
 func GeneratePoints(width, height, maxVal float64, picker func(*SystemStats) float64) string {
@@ -136,11 +290,16 @@ func GeneratePoints(width, height, maxVal float64, picker func(*SystemStats) flo
 		}
 	}
 
+	return polyline(width, height, maxVal, ordered, historySize)
+}
+
+// polyline renders values as SVG points spread over the given number of slots.
+func polyline(width, height, maxVal float64, ordered []float64, slots int) string {
 	if len(ordered) == 0 {
 		return ""
 	}
 
-	step := width / float64(historySize-1)
+	step := width / float64(slots-1)
 	if len(ordered) == 1 {
 		return fmt.Sprintf("0,%.2f %f,%.2f", height-(ordered[0]/maxVal)*height, width, height-(ordered[0]/maxVal)*height)
 	}
@@ -162,35 +321,161 @@ func GeneratePoints(width, height, maxVal float64, picker func(*SystemStats) flo
 	return points
 }
 
+// rangePoints renders a polyline for one series of the persisted history over span.
+func rangePoints(points []tsdb.Point, name string, width, height, maxVal float64) string {
+	values := tsdb.Resample(tsdb.Series(points, name), historySize)
+	if len(values) < 2 {
+		return polyline(width, height, maxVal, values, historySize)
+	}
+	return polyline(width, height, maxVal, values, len(values))
+}
+
 // FormatStats provides pre-formatted strings for easy HTML injection.
 type FormattedStats struct {
-	CPU          string
-	CPUCores     int
-	Mem          string
-	MemPct       string
-	Disk         string
-	DiskPct      string
-	DiskColor    string
-	NetRx        string
-	NetTx        string
-	CPUPoints    string
-	MemPoints    string
-	NetRxPoints  string
-	NetTxPoints  string
-}
-
-func GetFormatted() FormattedStats {
-	s := GetStats()
-	
-	diskColor := "bg-green-500"
-	if s.DiskUsage >= 90 {
-		diskColor = "bg-red-500"
-	} else if s.DiskUsage >= 70 {
-		diskColor = "bg-yellow-500"
+	CPU             string
+	CPUCores        int
+	Mem             string
+	MemPct          string
+	Disk            string
+	DiskPct         string
+	DiskColor       string
+	NetRx           string
+	NetTx           string
+	CPUPoints       string
+	MemPoints       string
+	NetRxPoints     string
+	NetTxPoints     string
+	Range           string
+	Cores           []FormattedCore
+	CPUUser         string
+	CPUSystem       string
+	CPUIowait       string
+	CPUSteal        string
+	Load            string
+	CtxSwitches     string
+	MemAvailable    string
+	MemCached       string
+	MemBuffers      string
+	Swap            string
+	SwapPct         string
+	SwapColor       string
+	Pressure        []FormattedPressure
+	DiskRead        string
+	DiskWrite       string
+	DiskIOPS        string
+	DiskAwait       string
+	DiskUtil        string
+	DiskIOPeak      string // full scale of the disk I/O sparklines
+	DiskReadPoints  string
+	DiskWritePoints string
+	Devices         []FormattedDevice
+	Sensors         []FormattedSensor
+	Interface       string // interface feeding NetRx/NetTx, empty for all
+	Interfaces      []FormattedInterface
+	Disks           []FormattedDisk
+}
+
+// FormattedPressure is one row of the dashboard's PSI table, averages given as
+// "avg10 / avg60 / avg300". Full is empty for cpu.
+type FormattedPressure struct {
+	Resource string // i18n key of the resource name
+	Some     string
+	Full     string
+}
+
+// FormattedDevice is one row of the dashboard's block device table.
+type FormattedDevice struct {
+	Name      string
+	Read      string
+	Write     string
+	IOPS      string
+	Await     string
+	Util      string
+	UtilColor string
+}
+
+// FormattedSensor is one temperature on the dashboard. Pct is the reading as a
+// share of the sensor's limit, or of 100 °C when the driver reports none.
+type FormattedSensor struct {
+	Name    string
+	Celsius string
+	Limit   string
+	Pct     string
+	Color   string
+}
+
+// FormattedInterface is one row of the dashboard's interface table. Packets are
+// per second, errors and drops cumulative, each as "rx / tx".
+type FormattedInterface struct {
+	Name    string
+	Rx      string
+	Tx      string
+	Packets string
+	Errors  string
+	Drops   string
+}
+
+// FormattedCore is one cell of the dashboard's per-core heatmap.
+type FormattedCore struct {
+	Name string
+	Pct  string
+	Hue  int // HSL hue from 120 (green, idle) to 0 (red, busy)
+}
+
+// FormattedDisk is one row of the dashboard's filesystem table.
+type FormattedDisk struct {
+	Mountpoint string
+	Device     string
+	FSType     string
+	Space      string
+	Pct        string
+	Color      string
+	InodesPct  string
+}
+
+// heatHue maps a utilisation in percent onto a green to red hue.
+func heatHue(pct float64) int {
+	if pct < 0 {
+		pct = 0
+	} else if pct > 100 {
+		pct = 100
+	}
+	return int(120 - pct*1.2)
+}
+
+// peak is the largest of values, at least 1 so a flat line stays at the bottom.
+func peak(values ...[]float64) float64 {
+	max := 1.0
+	for _, series := range values {
+		for _, v := range series {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	return max
+}
+
+// usageColor picks the progress bar colour for a fill level in percent.
+func usageColor(pct float64) string {
+	if pct >= 90 {
+		return "bg-red-500"
+	} else if pct >= 70 {
+		return "bg-yellow-500"
 	}
+	return "bg-green-500"
+}
+
+// GetFormatted renders the current stats. A non-zero span draws the sparklines
+// from the persisted history instead of the in-memory ring. A monitored iface
+// feeds the network card instead of the sum over all interfaces.
+func GetFormatted(span time.Duration, iface string) FormattedStats {
+	s := Latest()
+	
+	diskColor := usageColor(s.DiskUsage)
 
 	// Pre-generate SVG points for 100x30 default viewboxes
-	return FormattedStats{
+	f := FormattedStats{
 		CPU:      fmt.Sprintf("%.1f%%", s.CPUUsage),
 		CPUCores: s.CPUCores,
 		Mem:      fmt.Sprintf("%.2f GB / %.2f GB", formatMB(s.MemUsed)/1024, formatMB(s.MemTotal)/1024),
@@ -206,4 +491,144 @@ func GetFormatted() FormattedStats {
 		NetRxPoints: GeneratePoints(100, 30, 100, func(st *SystemStats) float64 { return st.NetRxSpeed }),
 		NetTxPoints: GeneratePoints(100, 30, 100, func(st *SystemStats) float64 { return st.NetTxSpeed }),
 	}
+
+	f.CPUUser = fmt.Sprintf("%.1f%%", s.CPUUser)
+	f.CPUSystem = fmt.Sprintf("%.1f%%", s.CPUSystem)
+	f.CPUIowait = fmt.Sprintf("%.1f%%", s.CPUIowait)
+	f.CPUSteal = fmt.Sprintf("%.1f%%", s.CPUSteal)
+	f.Load = fmt.Sprintf("%.2f / %.2f / %.2f", s.Load1, s.Load5, s.Load15)
+	f.CtxSwitches = fmt.Sprintf("%.0f/s", s.CtxSwitches)
+	for i, pct := range s.CPUPerCore {
+		f.Cores = append(f.Cores, FormattedCore{
+			Name: coreName(i),
+			Pct:  fmt.Sprintf("%.0f%%", pct),
+			Hue:  heatHue(pct),
+		})
+	}
+
+	for _, d := range s.Disks {
+		inodes := "-" // some filesystems (e.g. btrfs) have no fixed inode table
+		if d.InodesTotal > 0 {
+			inodes = fmt.Sprintf("%.1f%%", d.InodesUsage)
+		}
+		f.Disks = append(f.Disks, FormattedDisk{
+			Mountpoint: d.Mountpoint,
+			Device:     d.Device,
+			FSType:     d.FSType,
+			Space:      fmt.Sprintf("%.2f GB / %.2f GB", formatMB(d.Used)/1024, formatMB(d.Total)/1024),
+			Pct:        fmt.Sprintf("%.1f%%", d.Usage),
+			Color:      usageColor(d.Usage),
+			InodesPct:  inodes,
+		})
+	}
+
+	f.MemAvailable = fmt.Sprintf("%.2f GB", formatMB(s.MemAvailable)/1024)
+	f.MemCached = fmt.Sprintf("%.2f GB", formatMB(s.MemCached)/1024)
+	f.MemBuffers = fmt.Sprintf("%.2f GB", formatMB(s.MemBuffers)/1024)
+	f.Swap = fmt.Sprintf("%.2f GB / %.2f GB", formatMB(s.SwapUsed)/1024, formatMB(s.SwapTotal)/1024)
+	f.SwapPct = fmt.Sprintf("%.1f%%", s.SwapUsage)
+	f.SwapColor = usageColor(s.SwapUsage)
+	for _, resource := range pressureResources {
+		p, ok := s.Pressure[resource]
+		if !ok {
+			continue
+		}
+		row := FormattedPressure{
+			Resource: pressureLabels[resource],
+			Some:     fmt.Sprintf("%.2f / %.2f / %.2f", p.Some10, p.Some60, p.Some300),
+		}
+		if resource != "cpu" {
+			row.Full = fmt.Sprintf("%.2f / %.2f / %.2f", p.Full10, p.Full60, p.Full300)
+		}
+		f.Pressure = append(f.Pressure, row)
+	}
+
+	f.DiskRead = fmt.Sprintf("%.2f KB/s", s.DiskRead)
+	f.DiskWrite = fmt.Sprintf("%.2f KB/s", s.DiskWrite)
+	f.DiskIOPS = fmt.Sprintf("%.0f/s", s.DiskIOPS)
+	f.DiskAwait = fmt.Sprintf("%.2f ms", s.DiskAwait)
+	f.DiskUtil = fmt.Sprintf("%.1f%%", s.DiskUtil)
+	for _, d := range s.DiskIO {
+		f.Devices = append(f.Devices, FormattedDevice{
+			Name:      d.Name,
+			Read:      fmt.Sprintf("%.2f KB/s", d.ReadSpeed),
+			Write:     fmt.Sprintf("%.2f KB/s", d.WriteSpeed),
+			IOPS:      fmt.Sprintf("%.0f / %.0f", d.ReadIOPS, d.WriteIOPS),
+			Await:     fmt.Sprintf("%.2f ms", d.Await),
+			Util:      fmt.Sprintf("%.1f%%", d.Util),
+			UtilColor: usageColor(d.Util),
+		})
+	}
+
+	// Disk throughput has no natural ceiling, so both sparklines share the peak of the window
+	var reads, writes []float64
+	for _, st := range History() {
+		reads = append(reads, st.DiskRead)
+		writes = append(writes, st.DiskWrite)
+	}
+	ioPeak := peak(reads, writes)
+	f.DiskIOPeak = fmt.Sprintf("%.0f KB/s", ioPeak)
+	f.DiskReadPoints = GeneratePoints(100, 30, ioPeak, func(st *SystemStats) float64 { return st.DiskRead })
+	f.DiskWritePoints = GeneratePoints(100, 30, ioPeak, func(st *SystemStats) float64 { return st.DiskWrite })
+
+	for _, sensor := range s.Temperatures {
+		limit, row := sensor.Limit(), FormattedSensor{Name: sensor.Name, Celsius: fmt.Sprintf("%.1f °C", sensor.Celsius)}
+		if limit > 0 {
+			row.Limit = fmt.Sprintf("%.0f °C", limit)
+		} else {
+			limit = 100
+		}
+		pct := sensor.Celsius / limit * 100
+		if pct > 100 {
+			pct = 100
+		}
+		row.Pct = fmt.Sprintf("%.0f%%", pct)
+		row.Color = usageColor(pct)
+		f.Sensors = append(f.Sensors, row)
+	}
+
+	for _, n := range s.Interfaces {
+		f.Interfaces = append(f.Interfaces, FormattedInterface{
+			Name:    n.Name,
+			Rx:      fmt.Sprintf("%.2f KB/s", n.RxSpeed),
+			Tx:      fmt.Sprintf("%.2f KB/s", n.TxSpeed),
+			Packets: fmt.Sprintf("%.0f / %.0f", n.RxPackets, n.TxPackets),
+			Errors:  fmt.Sprintf("%d / %d", n.RxErrors, n.TxErrors),
+			Drops:   fmt.Sprintf("%d / %d", n.RxDrops, n.TxDrops),
+		})
+	}
+
+	rxSeries, txSeries := "NetRx", "NetTx"
+	if n, ok := s.Interface(iface); ok {
+		f.Interface = iface
+		f.NetRx = fmt.Sprintf("%.2f KB/s", n.RxSpeed)
+		f.NetTx = fmt.Sprintf("%.2f KB/s", n.TxSpeed)
+		f.NetRxPoints = GeneratePoints(100, 30, 100, func(st *SystemStats) float64 {
+			n, _ := st.Interface(iface)
+			return n.RxSpeed
+		})
+		f.NetTxPoints = GeneratePoints(100, 30, 100, func(st *SystemStats) float64 {
+			n, _ := st.Interface(iface)
+			return n.TxSpeed
+		})
+		rxSeries, txSeries = "NetRx:"+iface, "NetTx:"+iface
+	}
+
+	if span > 0 {
+		now := time.Now()
+		points, err := QueryHistory(now.Add(-span), now, "")
+		if err == nil {
+			f.Range = span.String()
+			f.CPUPoints = rangePoints(points, "CPU", 100, 30, 100)
+			f.MemPoints = rangePoints(points, "RAM", 100, 30, 100)
+			f.NetRxPoints = rangePoints(points, rxSeries, 100, 30, 100)
+			f.NetTxPoints = rangePoints(points, txSeries, 100, 30, 100)
+
+			ioPeak := peak(tsdb.Series(points, "DiskRead"), tsdb.Series(points, "DiskWrite"))
+			f.DiskIOPeak = fmt.Sprintf("%.0f KB/s", ioPeak)
+			f.DiskReadPoints = rangePoints(points, "DiskRead", 100, 30, ioPeak)
+			f.DiskWritePoints = rangePoints(points, "DiskWrite", 100, 30, ioPeak)
+		}
+	}
+	return f
 }
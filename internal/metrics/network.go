@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// NetInterface is the traffic of one network interface. Rates are per second
+// since the previous sample, counters are cumulative since boot.
+type NetInterface struct {
+	Name      string  `json:"name"`
+	RxBytes   uint64  `json:"rx_bytes"`
+	TxBytes   uint64  `json:"tx_bytes"`
+	RxSpeed   float64 `json:"rx_speed"`   // KB/s
+	TxSpeed   float64 `json:"tx_speed"`   // KB/s
+	RxPackets float64 `json:"rx_packets"` // packets/s
+	TxPackets float64 `json:"tx_packets"` // packets/s
+	RxErrors  uint64  `json:"rx_errors"`
+	TxErrors  uint64  `json:"tx_errors"`
+	RxDrops   uint64  `json:"rx_drops"`
+	TxDrops   uint64  `json:"tx_drops"`
+}
+
+// defaultInterfaceExclude hides loopback and the host side of container veth
+// pairs, which would otherwise count container traffic twice.
+var defaultInterfaceExclude = []string{"lo", "veth*"}
+
+var (
+	ifaceFilterMu sync.RWMutex
+	ifaceAllow    []string
+
+	lastIfaces map[string]net.IOCountersStat
+)
+
+// SetInterfaceFilter limits the monitored interfaces to those matching one of the
+// glob patterns. An empty list keeps every interface except loopback and veth pairs.
+func SetInterfaceFilter(allow []string) {
+	ifaceFilterMu.Lock()
+	defer ifaceFilterMu.Unlock()
+	ifaceAllow = allow
+}
+
+func interfaceAllowed(name string) bool {
+	ifaceFilterMu.RLock()
+	allow := ifaceAllow
+	ifaceFilterMu.RUnlock()
+
+	if len(allow) > 0 {
+		return matchAny(allow, name)
+	}
+	return !matchAny(defaultInterfaceExclude, name)
+}
+
+// rate is the per second increase of a counter, zero when it went backwards
+// because the interface was recreated.
+func rate(cur, prev uint64, seconds float64) float64 {
+	if cur < prev || seconds <= 0 {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+// collectNetwork fills the per-interface stats and the aggregate NetRx/NetTx
+// fields, which sum the monitored interfaces. Rates are deltas against the
+// previous sample, so the first sample and new interfaces report zero.
+func collectNetwork(stats *SystemStats) {
+	counters, err := net.IOCounters(true)
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	seconds := now.Sub(lastTime).Seconds()
+	current := make(map[string]net.IOCountersStat, len(counters))
+
+	for _, c := range counters {
+		if !interfaceAllowed(c.Name) {
+			continue
+		}
+		current[c.Name] = c
+
+		iface := NetInterface{
+			Name:     c.Name,
+			RxBytes:  c.BytesRecv,
+			TxBytes:  c.BytesSent,
+			RxErrors: c.Errin,
+			TxErrors: c.Errout,
+			RxDrops:  c.Dropin,
+			TxDrops:  c.Dropout,
+		}
+		if prev, ok := lastIfaces[c.Name]; ok && !lastTime.IsZero() {
+			iface.RxSpeed = rate(c.BytesRecv, prev.BytesRecv, seconds) / 1024
+			iface.TxSpeed = rate(c.BytesSent, prev.BytesSent, seconds) / 1024
+			iface.RxPackets = rate(c.PacketsRecv, prev.PacketsRecv, seconds)
+			iface.TxPackets = rate(c.PacketsSent, prev.PacketsSent, seconds)
+		}
+		stats.Interfaces = append(stats.Interfaces, iface)
+
+		stats.NetRx += iface.RxBytes
+		stats.NetTx += iface.TxBytes
+		stats.NetRxSpeed += iface.RxSpeed
+		stats.NetTxSpeed += iface.TxSpeed
+	}
+	sort.Slice(stats.Interfaces, func(i, j int) bool { return stats.Interfaces[i].Name < stats.Interfaces[j].Name })
+
+	lastIfaces = current
+	lastTime = now
+}
+
+// Interface returns the monitored interface called name.
+func (s *SystemStats) Interface(name string) (NetInterface, bool) {
+	for _, iface := range s.Interfaces {
+		if iface.Name == name {
+			return iface, true
+		}
+	}
+	return NetInterface{}, false
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sensor is one temperature reading in degrees Celsius. High and Critical are
+// the limits reported by the driver, zero when it has none.
+type Sensor struct {
+	Name     string  `json:"name"`
+	Celsius  float64 `json:"celsius"`
+	High     float64 `json:"high,omitempty"`
+	Critical float64 `json:"critical,omitempty"`
+}
+
+// sysRoots are tried in order; docker-compose.yml mounts the host's /sys at /host/sys.
+var sysRoots = []string{"/host/sys", "/sys"}
+
+// collectSensors reads hwmon temperature sensors, falling back to ACPI thermal
+// zones on machines without hwmon drivers, which report the same chips again.
+func collectSensors() []Sensor {
+	for _, root := range sysRoots {
+		if _, err := os.Stat(filepath.Join(root, "class")); err != nil {
+			continue
+		}
+		sensors := readHwmon(root)
+		if len(sensors) == 0 {
+			sensors = readThermalZones(root)
+		}
+		sort.Slice(sensors, func(i, j int) bool { return sensors[i].Name < sensors[j].Name })
+		return sensors
+	}
+	return nil
+}
+
+// readMilli parses a sysfs value in thousandths, such as millidegrees Celsius.
+func readMilli(path string) (float64, bool) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(string(raw)), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v / 1000, true
+}
+
+func readString(path string) string {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// sensorName builds a stable identifier such as "coretemp_package_id_0", usable
+// as an alert rule instance.
+func sensorName(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	name := strings.ToLower(strings.Join(kept, "_"))
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == ':' || r == ',' || r == '|' || r == '&' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func readHwmon(root string) []Sensor {
+	inputs, _ := filepath.Glob(filepath.Join(root, "class", "hwmon", "hwmon*", "temp*_input"))
+	sensors := make([]Sensor, 0, len(inputs))
+	seen := make(map[string]int)
+	for _, input := range inputs {
+		celsius, ok := readMilli(input)
+		if !ok {
+			continue
+		}
+		dir := filepath.Dir(input)
+		prefix := strings.TrimSuffix(filepath.Base(input), "_input")
+
+		label := readString(filepath.Join(dir, prefix+"_label"))
+		if label == "" {
+			label = prefix
+		}
+		name := sensorName(readString(filepath.Join(dir, "name")), label)
+		// Several chips of the same driver (e.g. one per NVMe drive) share a name
+		if seen[name]++; seen[name] > 1 {
+			name += "_" + strconv.Itoa(seen[name]-1)
+		}
+
+		sensor := Sensor{Name: name, Celsius: celsius}
+		sensor.High, _ = readMilli(filepath.Join(dir, prefix+"_max"))
+		sensor.Critical, _ = readMilli(filepath.Join(dir, prefix+"_crit"))
+		sensors = append(sensors, sensor)
+	}
+	return sensors
+}
+
+func readThermalZones(root string) []Sensor {
+	zones, _ := filepath.Glob(filepath.Join(root, "class", "thermal", "thermal_zone*"))
+	sensors := make([]Sensor, 0, len(zones))
+	for _, zone := range zones {
+		celsius, ok := readMilli(filepath.Join(zone, "temp"))
+		if !ok {
+			continue
+		}
+		sensor := Sensor{Name: sensorName(readString(filepath.Join(zone, "type")), filepath.Base(zone)), Celsius: celsius}
+
+		trips, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+		for _, trip := range trips {
+			temp, ok := readMilli(strings.TrimSuffix(trip, "_type") + "_temp")
+			if !ok {
+				continue
+			}
+			switch readString(trip) {
+			case "critical":
+				sensor.Critical = temp
+			case "hot":
+				sensor.High = temp
+			}
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors
+}
+
+// Limit is the temperature the sensor should stay below: the critical limit,
+// else the high one, zero when the driver reports neither.
+func (s Sensor) Limit() float64 {
+	if s.Critical > 0 {
+		return s.Critical
+	}
+	return s.High
+}
+
+// Sensor returns the temperature sensor called name.
+func (s *SystemStats) Sensor(name string) (Sensor, bool) {
+	for _, sensor := range s.Temperatures {
+		if sensor.Name == name {
+			return sensor, true
+		}
+	}
+	return Sensor{}, false
+}
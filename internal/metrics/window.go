@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/erysngl/zerostat/internal/tsdb"
+)
+
+// Window is one series over a stretch of recent history, oldest first, with
+// values converted to a threshold unit.
+type Window struct {
+	Times  []time.Time
+	Values []float64
+}
+
+// cachedRollups holds the closed rollups of one series last read for a span.
+// Trend alert rules are evaluated every few seconds but rollups only change once
+// per bucket, so the store is read at most once per bucket, series and span.
+type cachedRollups struct {
+	bucket time.Time
+	Window // native values
+}
+
+type windowKey struct {
+	name string
+	span time.Duration
+}
+
+var (
+	windowMu    sync.Mutex
+	windowCache = make(map[windowKey]cachedRollups)
+)
+
+// closedRollups returns the complete 1m rollups of the named series over the
+// last span, or the 1h rollups for spans longer than two days.
+func closedRollups(name string, span time.Duration, now time.Time) (Window, bool) {
+	historyMutex.RLock()
+	s := store
+	historyMutex.RUnlock()
+	if s == nil {
+		return Window{}, false
+	}
+
+	res, step := tsdb.Minute, time.Minute
+	if span > 48*time.Hour {
+		res, step = tsdb.Hour, time.Hour
+	}
+	bucket := now.Truncate(step)
+
+	key := windowKey{name: name, span: span}
+	windowMu.Lock()
+	defer windowMu.Unlock()
+	if cached, ok := windowCache[key]; ok && cached.bucket.Equal(bucket) {
+		return cached.Window, true
+	}
+
+	// Take every bucket overlapping the span. The open bucket starts at bucket and
+	// is still filling up, so stop short of it.
+	points, err := s.Query(now.Add(-span).Truncate(step), bucket.Add(-time.Nanosecond), res)
+	if err != nil {
+		return Window{}, false
+	}
+	var w Window
+	for _, p := range points {
+		if v, ok := p.Avg[name]; ok {
+			// An average stands for the middle of its bucket
+			w.Times = append(w.Times, p.Time.Add(step/2))
+			w.Values = append(w.Values, v)
+		}
+	}
+	windowCache[key] = cachedRollups{bucket: bucket, Window: w}
+	return w, true
+}
+
+// HistoryWindow returns the named series over the last span in unit, an empty
+// unit meaning the metric's default. With a history store it is made of the
+// rollup averages followed by the latest sample; without one only the in-memory
+// ring is available, which holds the last few minutes. The ring is also used for
+// instances the store has not been recording yet, see KeepHistory.
+func HistoryWindow(name, unit string, span time.Duration) Window {
+	now := time.Now()
+	var w Window
+	add := func(t time.Time, native float64) {
+		w.Times = append(w.Times, t)
+		w.Values = append(w.Values, convert(name, unit, native))
+	}
+
+	if rollups, ok := closedRollups(name, span, now); ok && len(rollups.Values) > 0 {
+		for i, t := range rollups.Times {
+			add(t, rollups.Values[i])
+		}
+		latest := Latest()
+		if v, ok := latest.Series()[name]; ok {
+			add(latest.Timestamp, v)
+		}
+		return w
+	}
+
+	cutoff := now.Add(-span)
+	for _, s := range History() {
+		if s.Timestamp.Before(cutoff) {
+			continue
+		}
+		if v, ok := s.Series()[name]; ok {
+			add(s.Timestamp, v)
+		}
+	}
+	return w
+}
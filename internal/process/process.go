@@ -19,13 +19,13 @@ import (
 
 // ProcessInfo represents a rich process trace
 type ProcessInfo struct {
-	PID           int32
-	User          string
-	CPU           float64
-	RAM           float32
-	Command       string
-	ContainerID   string
-	ContainerName string
+	PID           int32   `json:"pid"`
+	User          string  `json:"user"`
+	CPU           float64 `json:"cpu"`
+	RAM           float32 `json:"ram"`
+	Command       string  `json:"command"`
+	ContainerID   string  `json:"container_id"`
+	ContainerName string  `json:"container_name"`
 }
 
 // dockerClient provides a Unix socket HTTP client
@@ -120,8 +120,58 @@ func truncateID(id string) string {
 	return id
 }
 
+// ContainerStats aggregates every process mapped to a single container
+type ContainerStats struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Processes int     `json:"processes"`
+	CPU       float64 `json:"cpu"`
+	RAM       float64 `json:"ram"`
+}
+
+// GetContainerStats sums CPU / RAM usage of all processes per container.
+// Unlike GetProcesses it is not limited to the top 150 entries.
+func GetContainerStats() []ContainerStats {
+	byID := make(map[string]*ContainerStats)
+	for _, p := range listProcesses("") {
+		if p.ContainerID == "" {
+			continue
+		}
+		c, ok := byID[p.ContainerID]
+		if !ok {
+			c = &ContainerStats{ID: p.ContainerID, Name: p.ContainerName}
+			byID[p.ContainerID] = c
+		}
+		c.Processes++
+		c.CPU += p.CPU
+		c.RAM += float64(p.RAM)
+	}
+
+	results := make([]ContainerStats, 0, len(byID))
+	for _, c := range byID {
+		results = append(results, *c)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
 // GetProcesses fetches active processes and filters via query
 func GetProcesses(query string, sortBy string, sortDir string) []ProcessInfo {
+	results := listProcesses(query)
+	sortProcesses(results, sortBy, sortDir)
+
+	// Limit to top 150 for ui rendering perf
+	if len(results) > 150 {
+		results = results[:150]
+	}
+
+	return results
+}
+
+// listProcesses collects every process matching query along with its container mapping
+func listProcesses(query string) []ProcessInfo {
 	query = strings.ToLower(query)
 	cmap := GetContainersMap()
 
@@ -178,7 +228,11 @@ func GetProcesses(query string, sortBy string, sortDir string) []ProcessInfo {
 			ContainerName: containerName,
 		})
 	}
+	return results
+}
 
+// sortProcesses orders the list in place by the given column and direction
+func sortProcesses(results []ProcessInfo, sortBy string, sortDir string) {
 	// Dynamic Sorting
 	sort.Slice(results, func(i, j int) bool {
 		asc := sortDir == "asc"
@@ -231,13 +285,6 @@ func GetProcesses(query string, sortBy string, sortDir string) []ProcessInfo {
 			return results[i].CPU > results[j].CPU
 		}
 	})
-
-	// Limit to top 150 for ui rendering perf
-	if len(results) > 150 {
-		results = results[:150]
-	}
-
-	return results
 }
 
 func KillProcess(pid int32) error {
@@ -0,0 +1,82 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Version 1-Q "HELLO WORLD", the worked example of the standard's annex
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236}
+	want := []byte{168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16}
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	c := newCode(1)
+	c.drawFormat(5)
+	// Level M with mask 5 is 100000011001110, read from bit 14 down
+	want := "100000011001110"
+	var got strings.Builder
+	for i := 14; i >= 9; i-- {
+		got.WriteString(bit(c.Dark(14-i, 8)))
+	}
+	got.WriteString(bit(c.Dark(7, 8)))
+	got.WriteString(bit(c.Dark(8, 8)))
+	got.WriteString(bit(c.Dark(8, 7)))
+	for i := 5; i >= 0; i-- {
+		got.WriteString(bit(c.Dark(8, i)))
+	}
+	if got.String() != want {
+		t.Errorf("format bits = %s, want %s", got.String(), want)
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	c := newCode(7)
+	// Version 7 is 000111110010010100, stored bottom-left from bit 0 upwards
+	want := "000111110010010100"
+	var got strings.Builder
+	for i := 17; i >= 0; i-- {
+		got.WriteString(bit(c.Dark(i/3, c.Size-11+i%3)))
+	}
+	if got.String() != want {
+		t.Errorf("version bits = %s, want %s", got.String(), want)
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		n    int
+		size int
+	}{
+		{1, 21},
+		{14, 21},
+		{15, 25},
+		{122, 45},
+		{123, 49},
+		{213, 57},
+	}
+	for _, tt := range tests {
+		c, err := Encode(strings.Repeat("a", tt.n))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", tt.n, err)
+		}
+		if c.Size != tt.size {
+			t.Errorf("Encode(%d bytes).Size = %d, want %d", tt.n, c.Size, tt.size)
+		}
+	}
+	if _, err := Encode(strings.Repeat("a", 214)); err == nil {
+		t.Error("Encode(214 bytes) succeeded, want an error")
+	}
+}
+
+func bit(dark bool) string {
+	if dark {
+		return "1"
+	}
+	return "0"
+}
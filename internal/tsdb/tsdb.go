@@ -0,0 +1,445 @@
+package tsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolution identifies one of the storage tiers.
+type Resolution string
+
+const (
+	Raw    Resolution = "raw"
+	Minute Resolution = "1m"
+	Hour   Resolution = "1h"
+)
+
+const dayLayout = "2006-01-02"
+
+// Point is a single entry of a series set. Raw points only carry Avg (the sampled
+// value itself), rollups additionally carry the Min and Max seen inside the bucket.
+type Point struct {
+	Time  time.Time          `json:"t"`
+	Count int                `json:"n,omitempty"`
+	Avg   map[string]float64 `json:"avg"`
+	Min   map[string]float64 `json:"min,omitempty"`
+	Max   map[string]float64 `json:"max,omitempty"`
+}
+
+// Retention defines how long each tier is kept on disk.
+type Retention struct {
+	Raw    time.Duration
+	Minute time.Duration
+	Hour   time.Duration
+}
+
+// bucket accumulates samples until its time window closes.
+type bucket struct {
+	start time.Time
+	count int
+	sum   map[string]float64
+	min   map[string]float64
+	max   map[string]float64
+}
+
+// Store is an append-only, day-segmented JSON-lines store living under a directory:
+//
+//	<dir>/raw/2006-01-02.jsonl
+//	<dir>/1m/2006-01-02.jsonl
+//	<dir>/1h/2006-01-02.jsonl
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	retention Retention
+	minute    *bucket
+	hour      *bucket
+	lastPrune time.Time
+	closed    bool
+}
+
+// Open prepares the tier directories and returns a ready store.
+func Open(dir string, retention Retention) (*Store, error) {
+	for _, res := range []Resolution{Raw, Minute, Hour} {
+		if err := os.MkdirAll(filepath.Join(dir, string(res)), 0755); err != nil {
+			return nil, fmt.Errorf("tsdb: create %s tier: %w", res, err)
+		}
+	}
+	s := &Store{dir: dir, retention: retention}
+	s.prune(time.Now())
+	return s, nil
+}
+
+// SetRetention replaces the retention policy, applied on the next prune cycle.
+func (s *Store) SetRetention(retention Retention) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = retention
+	s.lastPrune = time.Time{}
+}
+
+// Append records a raw sample and feeds the 1m / 1h rollup buckets.
+func (s *Store) Append(t time.Time, values map[string]float64) {
+	if len(values) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	if err := s.write(Raw, Point{Time: t, Avg: values}); err != nil {
+		log.Printf("Warning: tsdb raw write failed: %v", err)
+	}
+
+	s.minute = s.roll(Minute, s.minute, t.Truncate(time.Minute), values)
+	s.hour = s.roll(Hour, s.hour, t.Truncate(time.Hour), values)
+
+	if t.Sub(s.lastPrune) >= time.Hour {
+		s.prune(t)
+	}
+}
+
+// roll adds values to the bucket starting at start, flushing the previous bucket
+// to the given tier once the window has moved on.
+func (s *Store) roll(res Resolution, b *bucket, start time.Time, values map[string]float64) *bucket {
+	if b != nil && !b.start.Equal(start) {
+		if err := s.write(res, b.point()); err != nil {
+			log.Printf("Warning: tsdb %s write failed: %v", res, err)
+		}
+		b = nil
+	}
+	if b == nil {
+		b = &bucket{
+			start: start,
+			sum:   make(map[string]float64),
+			min:   make(map[string]float64),
+			max:   make(map[string]float64),
+		}
+	}
+
+	b.count++
+	for k, v := range values {
+		b.sum[k] += v
+		if cur, ok := b.min[k]; !ok || v < cur {
+			b.min[k] = v
+		}
+		if cur, ok := b.max[k]; !ok || v > cur {
+			b.max[k] = v
+		}
+	}
+	return b
+}
+
+// Close writes the open rollup buckets so a restart does not lose the current
+// minute and hour. Later appends are ignored. A bucket written here and continued
+// by the next run ends up twice in its day file; Query merges the two.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var firstErr error
+	for _, open := range []struct {
+		res Resolution
+		b   *bucket
+	}{{Minute, s.minute}, {Hour, s.hour}} {
+		if open.b == nil {
+			continue
+		}
+		if err := s.write(open.res, open.b.point()); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("tsdb: flush %s bucket: %w", open.res, err)
+		}
+	}
+	s.minute, s.hour = nil, nil
+	return firstErr
+}
+
+func (b *bucket) point() Point {
+	p := Point{
+		Time:  b.start,
+		Count: b.count,
+		Avg:   make(map[string]float64, len(b.sum)),
+		Min:   make(map[string]float64, len(b.min)),
+		Max:   make(map[string]float64, len(b.max)),
+	}
+	for k, v := range b.sum {
+		p.Avg[k] = v / float64(b.count)
+	}
+	for k, v := range b.min {
+		p.Min[k] = v
+	}
+	for k, v := range b.max {
+		p.Max[k] = v
+	}
+	return p
+}
+
+func (s *Store) write(res Resolution, p Point) error {
+	line, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, string(res), p.Time.UTC().Format(dayLayout)+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Query returns every point of the given tier inside [from, to], oldest first.
+// For rollup tiers the still-open bucket is included so the tail is never empty.
+func (s *Store) Query(from, to time.Time, res Resolution) ([]Point, error) {
+	if res != Raw && res != Minute && res != Hour {
+		return nil, fmt.Errorf("tsdb: unknown resolution %q", res)
+	}
+
+	var points []Point
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to.UTC()); day = day.Add(24 * time.Hour) {
+		path := filepath.Join(s.dir, string(res), day.Format(dayLayout)+".jsonl")
+		dayPoints, err := readFile(path, from, to)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, dayPoints...)
+	}
+
+	// The day files are read without the lock so the collector never waits for
+	// them. The open bucket is taken afterwards: one closing in the meantime was
+	// either read already or is missing from this result, but never counted twice.
+	s.mu.Lock()
+	var open *bucket
+	switch res {
+	case Minute:
+		open = s.minute
+	case Hour:
+		open = s.hour
+	}
+	if open != nil && !open.start.Before(from) && !open.start.After(to) {
+		points = append(points, open.point())
+	}
+	s.mu.Unlock()
+
+	return mergeBuckets(points), nil
+}
+
+// mergeBuckets combines adjacent rollups of the same bucket, left behind when a
+// run flushed a bucket on shutdown and the next one continued it.
+func mergeBuckets(points []Point) []Point {
+	out := points[:0]
+	for _, p := range points {
+		n := len(out)
+		if n == 0 || !out[n-1].Time.Equal(p.Time) || p.Count == 0 || out[n-1].Count == 0 {
+			out = append(out, p)
+			continue
+		}
+		out[n-1] = mergePoints(out[n-1], p)
+	}
+	return out
+}
+
+// mergePoints combines two rollups of one bucket, weighting averages by count.
+func mergePoints(a, b Point) Point {
+	m := Point{
+		Time:  a.Time,
+		Count: a.Count + b.Count,
+		Avg:   make(map[string]float64, len(a.Avg)),
+		Min:   make(map[string]float64, len(a.Min)),
+		Max:   make(map[string]float64, len(a.Max)),
+	}
+	for k, v := range a.Avg {
+		m.Avg[k] = v
+	}
+	for k, v := range b.Avg {
+		if prev, ok := a.Avg[k]; ok {
+			v = (prev*float64(a.Count) + v*float64(b.Count)) / float64(m.Count)
+		}
+		m.Avg[k] = v
+	}
+	for k, v := range a.Min {
+		m.Min[k] = v
+	}
+	for k, v := range b.Min {
+		if cur, ok := m.Min[k]; !ok || v < cur {
+			m.Min[k] = v
+		}
+	}
+	for k, v := range a.Max {
+		m.Max[k] = v
+	}
+	for k, v := range b.Max {
+		if cur, ok := m.Max[k]; !ok || v > cur {
+			m.Max[k] = v
+		}
+	}
+	return m
+}
+
+// Downsample merges runs of adjacent points so at most limit remain, each stamped
+// with the time of its first point. Raw samples weigh as one sample each.
+func Downsample(points []Point, limit int) []Point {
+	if limit <= 0 || len(points) <= limit {
+		return points
+	}
+	per := (len(points) + limit - 1) / limit
+	out := make([]Point, 0, limit)
+	for i := 0; i < len(points); i += per {
+		merged := asRollup(points[i])
+		for _, p := range points[i+1 : min(i+per, len(points))] {
+			merged = mergePoints(merged, asRollup(p))
+		}
+		out = append(out, merged)
+	}
+	return out
+}
+
+// asRollup turns a raw sample into a rollup of one.
+func asRollup(p Point) Point {
+	if p.Count > 0 {
+		return p
+	}
+	p.Count = 1
+	p.Min, p.Max = p.Avg, p.Avg
+	return p
+}
+
+func readFile(path string, from, to time.Time) ([]Point, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []Point
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var p Point
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			// A torn write from a crash only loses that single line
+			continue
+		}
+		if p.Time.Before(from) || p.Time.After(to) {
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, scanner.Err()
+}
+
+// prune deletes day segments that fell entirely outside their tier's retention.
+func (s *Store) prune(now time.Time) {
+	s.lastPrune = now
+
+	tiers := map[Resolution]time.Duration{
+		Raw:    s.retention.Raw,
+		Minute: s.retention.Minute,
+		Hour:   s.retention.Hour,
+	}
+	for res, keep := range tiers {
+		if keep <= 0 {
+			continue
+		}
+		cutoff := now.Add(-keep).UTC()
+
+		entries, err := os.ReadDir(filepath.Join(s.dir, string(res)))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			day, err := time.Parse(dayLayout, strings.TrimSuffix(e.Name(), ".jsonl"))
+			if err != nil {
+				continue
+			}
+			if day.Add(24 * time.Hour).Before(cutoff) {
+				path := filepath.Join(s.dir, string(res), e.Name())
+				if err := os.Remove(path); err != nil {
+					log.Printf("Warning: tsdb failed to prune %s: %v", path, err)
+				}
+			}
+		}
+	}
+}
+
+// PickResolution chooses the coarsest tier that still gives a useful amount of
+// points for the requested span.
+func PickResolution(span time.Duration) Resolution {
+	switch {
+	case span <= 2*time.Hour:
+		return Raw
+	case span <= 48*time.Hour:
+		return Minute
+	default:
+		return Hour
+	}
+}
+
+// Series extracts one named series from a list of points as averages.
+func Series(points []Point, name string) []float64 {
+	values := make([]float64, 0, len(points))
+	for _, p := range points {
+		if v, ok := p.Avg[name]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Names lists every series name seen in the given points, sorted.
+func Names(points []Point) []string {
+	seen := make(map[string]bool)
+	for _, p := range points {
+		for k := range p.Avg {
+			seen[k] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for k := range seen {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resample averages values down to at most n evenly sized buckets.
+func Resample(values []float64, n int) []float64 {
+	if n <= 0 || len(values) <= n {
+		return values
+	}
+	out := make([]float64, n)
+	size := float64(len(values)) / float64(n)
+	for i := 0; i < n; i++ {
+		start := int(math.Floor(float64(i) * size))
+		end := int(math.Floor(float64(i+1) * size))
+		if end <= start {
+			end = start + 1
+		}
+		var sum float64
+		for _, v := range values[start:end] {
+			sum += v
+		}
+		out[i] = sum / float64(end-start)
+	}
+	return out
+}
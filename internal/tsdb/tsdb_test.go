@@ -0,0 +1,34 @@
+package tsdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDownsample(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i < 10; i++ {
+		points = append(points, Point{Time: start.Add(time.Duration(i) * time.Second), Avg: map[string]float64{"CPU": float64(i)}})
+	}
+
+	got := Downsample(points, 4)
+	if len(got) != 4 {
+		t.Fatalf("len = %d, want 4", len(got))
+	}
+	// Groups of three raw samples: 0-2, 3-5, 6-8 and 9
+	want := []struct{ avg, min, max float64 }{{1, 0, 2}, {4, 3, 5}, {7, 6, 8}, {9, 9, 9}}
+	for i, w := range want {
+		p := got[i]
+		if !p.Time.Equal(points[i*3].Time) {
+			t.Errorf("point %d time = %s, want %s", i, p.Time, points[i*3].Time)
+		}
+		if p.Avg["CPU"] != w.avg || p.Min["CPU"] != w.min || p.Max["CPU"] != w.max {
+			t.Errorf("point %d = avg %v min %v max %v, want %+v", i, p.Avg["CPU"], p.Min["CPU"], p.Max["CPU"], w)
+		}
+	}
+
+	if got := Downsample(points, 10); len(got) != 10 {
+		t.Errorf("len = %d, want the 10 points untouched", len(got))
+	}
+}